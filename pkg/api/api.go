@@ -67,10 +67,14 @@ func Start(ctx context.Context, wg *sync.WaitGroup, config configuration.Config,
 
 type Controller interface {
 	ListNotifications(token auth.Token, options persistence.ListOptions, channel model.Channel) (result model.NotificationList, err error, errCode int)
+	CountNotifications(token auth.Token, channel model.Channel) (result model.NotificationCounts, err error, errCode int)
 	ReadNotification(token auth.Token, id string) (result model.Notification, err error, errCode int)
-	CreateNotification(token *auth.Token, notification model.Notification, ignoreDuplicatesWithinSeconds *int64) (result model.Notification, err error, errCode int)
+	CreateNotification(token *auth.Token, notification model.Notification, ignoreDuplicatesWithinSeconds *int64, pushDuplicates bool) (result model.Notification, err error, errCode int)
 	SetNotification(token auth.Token, notification model.Notification) (result model.Notification, err error, errCode int)
 	DeleteMultipleNotifications(token auth.Token, ids []string) (err error, errCode int)
+	SetNotificationsReadState(token auth.Token, request model.NotificationReadStateRequest) (result model.NotificationReadState, err error, errCode int)
+	ListNotificationDeliveries(token auth.Token, id string) (result model.NotificationDeliveries, err error, errCode int)
+	ResolveNotification(token *auth.Token, request model.NotificationResolveRequest) (result model.Notification, err error, errCode int)
 	HandleWs(conn *websocket.Conn)
 
 	ListBrokers(token auth.Token, options persistence.ListOptions) (result model.BrokerList, err error, errCode int)
@@ -78,6 +82,8 @@ type Controller interface {
 	CreateBroker(token auth.Token, broker model.Broker) (result model.Broker, err error, errCode int)
 	SetBroker(token auth.Token, broker model.Broker) (result model.Broker, err error, errCode int)
 	DeleteMultipleBrokers(token auth.Token, ids []string) (err error, errCode int)
+	TestBroker(token auth.Token, broker model.Broker) (result model.BrokerTestResult, err error, errCode int)
+	TestStoredBroker(token auth.Token, id string) (result model.BrokerTestResult, err error, errCode int)
 
 	GetPlatformBroker(token auth.Token) (platformBroker model.PlatformBroker, err error, errCode int)
 	SetPlatformBroker(token auth.Token, platformBroker model.PlatformBroker) (result model.PlatformBroker, err error, errCode int)
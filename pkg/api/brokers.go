@@ -60,6 +60,11 @@ func BrokersEndpoints(_ configuration.Config, control Controller, router *mux.Ro
 			http.Error(writer, err.Error(), http.StatusBadRequest)
 			return
 		}
+		err = parseCursorOptions(request.URL.Query(), &options)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
 
 		result, err, errCode := control.ListBrokers(token, options)
 		if err != nil {
@@ -120,6 +125,51 @@ func BrokersEndpoints(_ configuration.Config, control Controller, router *mux.Ro
 		return
 	}).Methods(http.MethodPost)
 
+	router.HandleFunc(resource+"/test", func(writer http.ResponseWriter, request *http.Request) {
+		token, err := auth.GetParsedToken(request)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		broker := model.Broker{}
+		err = json.NewDecoder(request.Body).Decode(&broker)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result, err, errCode := control.TestBroker(token, broker)
+		if err != nil {
+			http.Error(writer, err.Error(), errCode)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+		err = json.NewEncoder(writer).Encode(result)
+		if err != nil {
+			log.Println("ERROR: unable to encode response", err)
+		}
+		return
+	}).Methods(http.MethodPost)
+
+	router.HandleFunc(resource+"/{id}/test", func(writer http.ResponseWriter, request *http.Request) {
+		id := mux.Vars(request)["id"]
+		token, err := auth.GetParsedToken(request)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		result, err, errCode := control.TestStoredBroker(token, id)
+		if err != nil {
+			http.Error(writer, err.Error(), errCode)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+		err = json.NewEncoder(writer).Encode(result)
+		if err != nil {
+			log.Println("ERROR: unable to encode response", err)
+		}
+		return
+	}).Methods(http.MethodPost)
+
 	router.HandleFunc(resource+"/{id}", func(writer http.ResponseWriter, request *http.Request) {
 		id := mux.Vars(request)["id"]
 		token, err := auth.GetParsedToken(request)
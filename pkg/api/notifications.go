@@ -18,6 +18,7 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/SENERGY-Platform/notifier/pkg/auth"
 	"github.com/SENERGY-Platform/notifier/pkg/configuration"
 	"github.com/SENERGY-Platform/notifier/pkg/model"
@@ -25,7 +26,10 @@ import (
 	"github.com/gorilla/mux"
 	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 func init() {
@@ -41,21 +45,7 @@ func NotificationsEndpoints(_ configuration.Config, control Controller, router *
 			http.Error(writer, err.Error(), http.StatusUnauthorized)
 			return
 		}
-		options := persistence.ListOptions{}
-		limitStr := request.URL.Query().Get("limit")
-		if limitStr == "" {
-			limitStr = "100"
-		}
-		options.Limit, err = strconv.Atoi(limitStr)
-		if err != nil {
-			http.Error(writer, err.Error(), http.StatusBadRequest)
-			return
-		}
-		offsetStr := request.URL.Query().Get("offset")
-		if offsetStr == "" {
-			offsetStr = "0"
-		}
-		options.Offset, err = strconv.Atoi(offsetStr)
+		options, err := parseNotificationListOptions(request)
 		if err != nil {
 			http.Error(writer, err.Error(), http.StatusBadRequest)
 			return
@@ -128,7 +118,16 @@ func NotificationsEndpoints(_ configuration.Config, control Controller, router *
 			}
 			ignoreDuplicatesWithinSeconds = &x
 		}
-		result, err, errCode := control.CreateNotification(token, notification, ignoreDuplicatesWithinSeconds)
+		pushDuplicates := false
+		pushDuplicatesParam := request.URL.Query().Get("push_duplicates")
+		if len(pushDuplicatesParam) > 0 {
+			pushDuplicates, err = strconv.ParseBool(pushDuplicatesParam)
+			if err != nil {
+				http.Error(writer, "Could not parse query parameter push_duplicates", http.StatusBadRequest)
+				return
+			}
+		}
+		result, err, errCode := control.CreateNotification(token, notification, ignoreDuplicatesWithinSeconds, pushDuplicates)
 		if err != nil {
 			http.Error(writer, err.Error(), errCode)
 			return
@@ -141,6 +140,110 @@ func NotificationsEndpoints(_ configuration.Config, control Controller, router *
 		return
 	}).Methods(http.MethodPut, http.MethodPost, http.MethodOptions) //legacy uses PUT
 
+	router.HandleFunc(resource+"/count", func(writer http.ResponseWriter, request *http.Request) {
+		token, err := auth.GetParsedToken(request)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		result, err, errCode := control.CountNotifications(token, request.URL.Query().Get("channel"))
+		if err != nil {
+			http.Error(writer, err.Error(), errCode)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+		err = json.NewEncoder(writer).Encode(result)
+		if err != nil {
+			log.Println("ERROR: unable to encode response", err)
+		}
+		return
+	}).Methods(http.MethodGet, http.MethodOptions)
+
+	router.HandleFunc(resource+"/read-state", func(writer http.ResponseWriter, request *http.Request) {
+		token, err := auth.GetParsedToken(request)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		readStateRequest := model.NotificationReadStateRequest{}
+		err = json.NewDecoder(request.Body).Decode(&readStateRequest)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result, err, errCode := control.SetNotificationsReadState(token, readStateRequest)
+		if err != nil {
+			http.Error(writer, err.Error(), errCode)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+		err = json.NewEncoder(writer).Encode(result)
+		if err != nil {
+			log.Println("ERROR: unable to encode response", err)
+		}
+		return
+	}).Methods(http.MethodPut, http.MethodOptions)
+
+	router.HandleFunc(resource+"/resolve", func(writer http.ResponseWriter, request *http.Request) {
+		resolveRequest := model.NotificationResolveRequest{}
+		err := json.NewDecoder(request.Body).Decode(&resolveRequest)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var token *auth.Token
+		if len(auth.GetAuthToken(request)) > 0 { // access without token = admin
+			tokenT, err := auth.GetParsedToken(request)
+			if err != nil {
+				http.Error(writer, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			token = &tokenT
+		}
+		if resolveRequest.UserId == "" {
+			if token == nil {
+				http.Error(writer, "missing user id", http.StatusBadRequest)
+				return
+			}
+			resolveRequest.UserId = token.GetUserId()
+		}
+		if token != nil && token.GetUserId() != resolveRequest.UserId {
+			http.Error(writer, "You may only resolve your own notifications", http.StatusUnauthorized)
+			return
+		}
+		result, err, errCode := control.ResolveNotification(token, resolveRequest)
+		if err != nil {
+			http.Error(writer, err.Error(), errCode)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+		err = json.NewEncoder(writer).Encode(result)
+		if err != nil {
+			log.Println("ERROR: unable to encode response", err)
+		}
+		return
+	}).Methods(http.MethodPost, http.MethodOptions)
+
+	router.HandleFunc(resource+"/{id}/deliveries", func(writer http.ResponseWriter, request *http.Request) {
+		id := mux.Vars(request)["id"]
+		token, err := auth.GetParsedToken(request)
+		if err != nil {
+			http.Error(writer, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		result, err, errCode := control.ListNotificationDeliveries(token, id)
+		if err != nil {
+			http.Error(writer, err.Error(), errCode)
+			return
+		}
+		writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+		err = json.NewEncoder(writer).Encode(result)
+		if err != nil {
+			log.Println("ERROR: unable to encode response", err)
+		}
+		return
+	}).Methods(http.MethodGet, http.MethodOptions)
+
 	router.HandleFunc(resource+"/{id}", func(writer http.ResponseWriter, request *http.Request) {
 		id := mux.Vars(request)["id"]
 		token, err := auth.GetParsedToken(request)
@@ -211,3 +314,100 @@ func NotificationsEndpoints(_ configuration.Config, control Controller, router *
 	}).Methods(http.MethodPut, http.MethodPost, http.MethodOptions) //legacy uses POST
 
 }
+
+func parseNotificationListOptions(request *http.Request) (options persistence.ListOptions, err error) {
+	query := request.URL.Query()
+	limitStr := query.Get("limit")
+	if limitStr == "" {
+		limitStr = "100"
+	}
+	options.Limit, err = strconv.Atoi(limitStr)
+	if err != nil {
+		return options, err
+	}
+	offsetStr := query.Get("offset")
+	if offsetStr == "" {
+		offsetStr = "0"
+	}
+	options.Offset, err = strconv.Atoi(offsetStr)
+	if err != nil {
+		return options, err
+	}
+	err = parseCursorOptions(query, &options)
+	if err != nil {
+		return options, err
+	}
+	options.Search = strings.TrimSpace(query.Get("q"))
+	if isReadStr := query.Get("isRead"); isReadStr != "" {
+		isRead, err := strconv.ParseBool(isReadStr)
+		if err != nil {
+			return options, fmt.Errorf("invalid isRead: %w", err)
+		}
+		options.IsRead = &isRead
+	}
+	for key, values := range query {
+		label, ok := strings.CutPrefix(key, "label.")
+		if !ok || len(values) == 0 {
+			continue
+		}
+		if options.Labels == nil {
+			options.Labels = map[string]string{}
+		}
+		options.Labels[label] = values[0]
+	}
+	for _, topics := range query["topic"] {
+		for _, topic := range strings.Split(topics, ",") {
+			if topic = strings.TrimSpace(topic); topic != "" {
+				options.Topics = append(options.Topics, topic)
+			}
+		}
+	}
+	if createdAfterStr := query.Get("created_after"); createdAfterStr != "" {
+		createdAfter, err := time.Parse(time.RFC3339, createdAfterStr)
+		if err != nil {
+			return options, fmt.Errorf("invalid created_after: %w", err)
+		}
+		options.CreatedAfter = &createdAfter
+	}
+	if createdBeforeStr := query.Get("created_before"); createdBeforeStr != "" {
+		createdBefore, err := time.Parse(time.RFC3339, createdBeforeStr)
+		if err != nil {
+			return options, fmt.Errorf("invalid created_before: %w", err)
+		}
+		options.CreatedBefore = &createdBefore
+	}
+	if sortStr := query.Get("sort"); sortStr != "" {
+		field, direction, _ := strings.Cut(sortStr, ".")
+		if field != persistence.SortByCreatedAt && field != persistence.SortByTitle {
+			return options, fmt.Errorf("invalid sort field %s", field)
+		}
+		switch direction {
+		case "", "asc":
+		case "desc":
+			options.SortDesc = true
+		default:
+			return options, fmt.Errorf("invalid sort direction %s", direction)
+		}
+		options.SortBy = field
+	}
+	return options, nil
+}
+
+// parseCursorOptions switches to cursor paging if the cursor parameter is present (an empty value requests the first page).
+// The total is counted by default for offset paging and only on request (with_total=true) for cursor paging.
+func parseCursorOptions(query url.Values, options *persistence.ListOptions) error {
+	options.UseCursor = query.Has("cursor")
+	if options.UseCursor {
+		options.Cursor = query.Get("cursor")
+		options.Offset = 0
+	}
+	options.SkipTotal = options.UseCursor
+	if withTotalStr := query.Get("with_total"); withTotalStr != "" {
+		withTotal, err := strconv.ParseBool(withTotalStr)
+		if err != nil {
+			return fmt.Errorf("invalid with_total: %w", err)
+		}
+		options.SkipTotal = !withTotal
+	}
+	return nil
+}
@@ -28,23 +28,50 @@ import (
 )
 
 type Config struct {
-	ApiPort                       string `json:"api_port"`
-	MongoAddr                     string `json:"mongo_addr"`
-	MongoPort                     string `json:"mongo_port"`
-	MongoTable                    string `json:"mongo_table"`
-	MongoNotificationCollection   string `json:"mongo_notification_collection"`
-	MongoBrokerCollection         string `json:"mongo_broker_collection"`
-	MongoPlatformBrokerCollection string `json:"mongo_platformbroker_collection"`
-	MongoSettingsCollection       string `json:"mongo_settings_collection"`
-	Debug                         bool   `json:"debug"`
-	JwtSigningKey                 string `json:"jwt_signing_key"` //without -----BEGIN PUBLIC KEY-----
-	WsPingPeriod                  string `json:"ws_ping_period"`
-	PlatformMqttAddress           string `json:"platform_mqtt_address"`
-	PlatformMqttUser              string `json:"platform_mqtt_user"`
-	PlatformMqttPw                string `json:"platform_mqtt_pw"`
-	PlatformMqttQos               uint8  `json:"platform_mqtt_qos"`
-	PlatformMqttBasetopic         string `json:"platform_mqtt_basetopic"`
-	MqttClientPrefix              string `json:"mqtt_client_prefix"`
+	ApiPort                         string `json:"api_port"`
+	MongoAddr                       string `json:"mongo_addr"`
+	MongoPort                       string `json:"mongo_port"`
+	MongoTable                      string `json:"mongo_table"`
+	MongoNotificationCollection     string `json:"mongo_notification_collection"`
+	MongoBrokerCollection           string `json:"mongo_broker_collection"`
+	MongoPlatformBrokerCollection   string `json:"mongo_platformbroker_collection"`
+	MongoSettingsCollection         string `json:"mongo_settings_collection"`
+	MongoDeferredDeliveryCollection string `json:"mongo_deferred_delivery_collection"`
+	MongoEmailDigestCollection      string `json:"mongo_email_digest_collection"`
+	MongoOutboxCollection           string `json:"mongo_outbox_collection"`
+	MongoDeliveryCollection         string `json:"mongo_delivery_collection"`
+	Debug                           bool   `json:"debug"`
+	JwtSigningKey                   string `json:"jwt_signing_key"` //without -----BEGIN PUBLIC KEY-----
+	WsPingPeriod                    string `json:"ws_ping_period"`
+	NotificationCleanupInterval     string `json:"notification_cleanup_interval"`
+	ScheduledDeliveryInterval       string `json:"scheduled_delivery_interval"` // polling of scheduled notifications, quiet hour deferrals and email digests; required
+	OutboxWorkers                   int64  `json:"outbox_workers"`              // 0 disables the outbox, deliveries are not retried
+	OutboxPollInterval              string `json:"outbox_poll_interval"`
+	OutboxMaxAttempts               int64  `json:"outbox_max_attempts"`
+	OutboxRetryBackoff              string `json:"outbox_retry_backoff"` // doubled with each attempt
+	OutboxMaxRetryBackoff           string `json:"outbox_max_retry_backoff"`
+	PlatformMqttAddress             string `json:"platform_mqtt_address"`
+	PlatformMqttUser                string `json:"platform_mqtt_user"`
+	PlatformMqttPw                  string `json:"platform_mqtt_pw"`
+	PlatformMqttQos                 uint8  `json:"platform_mqtt_qos"`
+	PlatformMqttBasetopic           string `json:"platform_mqtt_basetopic"`
+	PlatformMqttCaCertFile          string `json:"platform_mqtt_ca_cert_file"`     // PEM file, optional
+	PlatformMqttClientCertFile      string `json:"platform_mqtt_client_cert_file"` // PEM file, optional, enables mutual tls with platform_mqtt_client_key_file
+	PlatformMqttClientKeyFile       string `json:"platform_mqtt_client_key_file"`
+	PlatformMqttInsecure            bool   `json:"platform_mqtt_insecure"`
+	MqttClientPrefix                string `json:"mqtt_client_prefix"`
+	MqttIngestionTopic              string `json:"mqtt_ingestion_topic"`       // platform broker topic to create notifications, e.g. notifier/create/{userId}; "" or "-" disables the ingestion
+	MqttIngestionErrorTopic         string `json:"mqtt_ingestion_error_topic"` // receives invalid messages, server errors are retried
+	MqttIngestionShareGroup         string `json:"mqtt_ingestion_share_group"` // shared subscription group, so that each message is handled by one instance; "" subscribes directly
+	KafkaUrl                        string `json:"kafka_url"`                  // "" or "-" disables the kafka consumer
+	KafkaNotificationTopic          string `json:"kafka_notification_topic"`
+	KafkaConsumerGroup              string `json:"kafka_consumer_group"`
+	KafkaRetryInterval              string `json:"kafka_retry_interval"`            // wait before retrying messages that could not be persisted
+	KafkaMaxRetries                 int64  `json:"kafka_max_retries"`               // negative retries forever
+	KafkaDeadLetterTopic            string `json:"kafka_dead_letter_topic"`         // "" or "-" drops messages that can not be persisted
+	MqttPoolIdleTimeout             string `json:"mqtt_pool_idle_timeout"`          // pooled user broker connections are closed after this duration without publish
+	BrokerMaxConsecutiveFailures    int64  `json:"broker_max_consecutive_failures"` // user brokers are disabled after this many failed deliveries in a row, 0 never disables
+	MqttPoolMaxConcurrency          int64  `json:"mqtt_pool_max_concurrency"`       // max parallel publishes to user brokers
 
 	KeycloakUrl          string `json:"keycloak_url"`
 	KeycloakRealm        string `json:"keycloak_realm"`
@@ -62,6 +89,7 @@ type Config struct {
 
 	MailpitHostPort string `json:"mailpit_host_port"`
 	EmailFrom       string `json:"email_from"`
+	UiBaseUrl       string `json:"ui_base_url"` // prefix for relative notification links in emails
 }
 
 // loads config from json in location and used environment variables (e.g ZookeeperUrl --> ZOOKEEPER_URL)
@@ -0,0 +1,76 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/SENERGY-Platform/notifier/pkg/model"
+)
+
+// brokerSuccessRefresh limits how often successful deliveries to healthy brokers are stored
+const brokerSuccessRefresh = time.Minute
+
+// updateBrokerHealth stores the result of a delivery to the broker and disables it after too many consecutive failures.
+// countFailure is false for retries of a notification, so that failures are counted per notification and not per attempt;
+// their error is still stored. broker is the state read before the delivery.
+func (this *Controller) updateBrokerHealth(broker model.Broker, deliveryErr error, countFailure bool) {
+	now := time.Now().Truncate(time.Millisecond)
+	if deliveryErr == nil {
+		if broker.Health.ConsecutiveFailures == 0 && broker.Health.LastSuccessAt != nil && now.Sub(*broker.Health.LastSuccessAt) < brokerSuccessRefresh {
+			return
+		}
+		err := this.db.SetBrokerDeliverySuccess(broker.Id, now, now.Add(-brokerSuccessRefresh))
+		if err != nil {
+			log.Println("ERROR: unable to update broker health", broker.Id, err)
+		}
+		return
+	}
+	if !countFailure {
+		err := this.db.SetBrokerDeliveryError(broker.Id, now, deliveryErr.Error())
+		if err != nil {
+			log.Println("ERROR: unable to update broker health", broker.Id, err)
+		}
+		return
+	}
+	disabled, err := this.db.AddBrokerDeliveryFailure(broker.Id, now, deliveryErr.Error(), this.config.BrokerMaxConsecutiveFailures)
+	if err != nil {
+		log.Println("ERROR: unable to update broker health", broker.Id, err)
+		return
+	}
+	if disabled {
+		log.Println("WARNING: disabled broker", broker.Id, "of user", broker.UserId, "after", this.config.BrokerMaxConsecutiveFailures, "consecutive failures")
+		this.mqttPool.Invalidate(broker.Id)
+		go this.notifyBrokerDisabled(broker, deliveryErr)
+	}
+}
+
+func (this *Controller) notifyBrokerDisabled(broker model.Broker, deliveryErr error) {
+	_, err, _ := this.CreateNotification(nil, model.Notification{
+		UserId:         broker.UserId,
+		Title:          "MQTT broker disabled",
+		Message:        fmt.Sprintf("The MQTT broker %s (topic %s) was disabled after %d consecutive failed deliveries. Last error: %s. Please check the broker settings and enable it again.", broker.Address, broker.Topic, this.config.BrokerMaxConsecutiveFailures, deliveryErr.Error()),
+		Topic:          model.TopicDeveloper,
+		Severity:       model.SeverityWarning,
+		CorrelationKey: "broker_disabled_" + broker.Id,
+	}, nil, false)
+	if err != nil {
+		log.Println("ERROR: unable to notify user about disabled broker", broker.Id, err)
+	}
+}
@@ -17,18 +17,26 @@
 package controller
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/SENERGY-Platform/notifier/pkg/auth"
 	"github.com/SENERGY-Platform/notifier/pkg/model"
+	"github.com/SENERGY-Platform/notifier/pkg/mqtt"
 	"github.com/SENERGY-Platform/notifier/pkg/persistence"
 	"github.com/google/uuid"
 	"net/http"
+	"slices"
 	"time"
 )
 
 func (this *Controller) ListBrokers(token auth.Token, options persistence.ListOptions) (result model.BrokerList, err error, errCode int) {
 	result.Limit, result.Offset = options.Limit, options.Offset
 	result.Brokers, result.Total, err, errCode = this.db.ListBrokers(token.GetUserId(), options)
+	if err == nil && options.UseCursor && options.Limit > 0 && len(result.Brokers) == options.Limit {
+		last := result.Brokers[len(result.Brokers)-1]
+		result.NextCursor = persistence.EncodeCursor(last.CreatedAt, last.Id)
+	}
 	return
 }
 
@@ -44,11 +52,13 @@ func (this *Controller) CreateBroker(token auth.Token, broker model.Broker) (res
 	if broker.Id != "" {
 		return result, errors.New("specifing id is not allowed"), http.StatusBadRequest
 	}
-	if broker.Address == "" {
-		return result, errors.New("empty address not allowed"), http.StatusBadRequest
+	err = validateBroker(broker)
+	if err != nil {
+		return result, err, http.StatusBadRequest
 	}
 	broker.Id = uuid.NewString()
 	broker.UserId = token.GetUserId()
+	broker.Health = model.BrokerHealth{}
 	broker.CreatedAt = time.Now().Truncate(time.Millisecond)
 	broker.UpdatedAt = broker.CreatedAt
 	err, errCode = this.db.SetBroker(broker)
@@ -56,18 +66,161 @@ func (this *Controller) CreateBroker(token auth.Token, broker model.Broker) (res
 }
 
 func (this *Controller) SetBroker(token auth.Token, broker model.Broker) (result model.Broker, err error, errCode int) {
-	if broker.Address == "" {
-		return result, errors.New("empty address not allowed"), http.StatusBadRequest
+	err = validateBroker(broker)
+	if err != nil {
+		return result, err, http.StatusBadRequest
 	}
-	_, err, errCode = this.db.ReadBroker(token.GetUserId(), broker.Id) // Check existence before set
+	existing, err, errCode := this.db.ReadBroker(token.GetUserId(), broker.Id) // Check existence before set
 	if err != nil {
 		return model.Broker{}, err, errCode
 	}
+	broker.Health = existing.Health
+	if broker.Enabled && !existing.Enabled {
+		// re-enabled by the user, the broker gets a fresh start
+		broker.Health.ConsecutiveFailures = 0
+		broker.Health.DisabledAt = nil
+	}
 	broker.UpdatedAt = time.Now().Truncate(time.Millisecond)
 	err, errCode = this.db.SetBroker(broker)
+	this.mqttPool.Invalidate(broker.Id) // connect with the new settings on the next publish
 	return broker, err, errCode
 }
 
 func (this *Controller) DeleteMultipleBrokers(token auth.Token, ids []string) (err error, errCode int) {
-	return this.db.RemoveBrokers(token.GetUserId(), ids)
+	err, errCode = this.db.RemoveBrokers(token.GetUserId(), ids)
+	if err != nil {
+		return err, errCode
+	}
+	for _, id := range ids {
+		this.mqttPool.Invalidate(id)
+	}
+	return nil, errCode
+}
+
+// TestBroker connects to the unsaved broker and publishes a test message to its topic.
+// Connection and publish problems are reported in the result and not as error.
+func (this *Controller) TestBroker(token auth.Token, broker model.Broker) (result model.BrokerTestResult, err error, errCode int) {
+	err = validateBroker(broker)
+	if err != nil {
+		return result, err, http.StatusBadRequest
+	}
+	return this.testBroker(broker), nil, http.StatusOK
+}
+
+// TestStoredBroker tests the saved broker like TestBroker, using its credentials from vault
+func (this *Controller) TestStoredBroker(token auth.Token, id string) (result model.BrokerTestResult, err error, errCode int) {
+	broker, err, errCode := this.db.ReadBroker(token.GetUserId(), id)
+	if err != nil {
+		return result, err, errCode
+	}
+	return this.testBroker(broker), nil, http.StatusOK
+}
+
+func (this *Controller) testBroker(broker model.Broker) (result model.BrokerTestResult) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel() // the test does not use the pool, its connection is closed afterward
+	start := time.Now()
+	publisher, err := mqtt.Connect(ctx, brokerPoolKey(broker), this.config.MqttClientPrefix+uuid.NewString(), this.config.Debug)
+	result.ConnectLatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Connected = true
+	start = time.Now()
+	// the test notification is published like real ones, so that the test covers the payload and topic settings
+	notification := brokerTestNotification(broker)
+	message, _, err := broker.MqttPayloadOptions.Render(&notification, notification)
+	var topic string
+	if err == nil {
+		topic, err = broker.RenderTopic(notification)
+	}
+	if err == nil {
+		err = publisher.PublishMessage(mqtt.Message{
+			Topic:          topic,
+			Payload:        message,
+			Retain:         broker.Retain,
+			ContentType:    broker.ContentType(),
+			UserProperties: mqttUserProperties(&notification),
+			MessageExpiry:  broker.MessageExpirySeconds,
+		})
+	}
+	result.PublishLatencyMs = time.Since(start).Milliseconds()
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Published = true
+	return result
+}
+
+func validateBroker(broker model.Broker) error {
+	if broker.Address == "" {
+		return errors.New("empty address not allowed")
+	}
+	_, err := mqtt.BrokerUrl(broker.Address)
+	if err != nil {
+		return err
+	}
+	_, err = brokerTLSOptions(broker).Config()
+	if err != nil {
+		return err
+	}
+	err = broker.MqttPayloadOptions.Validate()
+	if err != nil {
+		return err
+	}
+	if broker.ProtocolVersion != 0 && broker.ProtocolVersion != model.MqttProtocolVersion311 && broker.ProtocolVersion != model.MqttProtocolVersion5 {
+		return fmt.Errorf("unsupported protocol version %d", broker.ProtocolVersion)
+	}
+	if broker.MessageExpirySeconds > 0 && broker.ProtocolVersion != model.MqttProtocolVersion5 {
+		return errors.New("message expiry requires protocol version 5")
+	}
+	for _, topic := range broker.Topics {
+		if !slices.Contains(append(model.AllTopics(), model.TopicUnknown), topic) {
+			return fmt.Errorf("unknown topic %s", topic)
+		}
+	}
+	_, err = broker.RenderTopic(brokerTestNotification(broker))
+	if err != nil {
+		return fmt.Errorf("invalid topic template: %w", err)
+	}
+	return nil
+}
+
+// brokerTestNotification is used to check topic templates and for broker tests
+func brokerTestNotification(broker model.Broker) model.Notification {
+	notification := model.Notification{
+		Id:        "test",
+		Title:     "Broker test",
+		Message:   "Test message of the notifier",
+		Topic:     model.TopicUnknown,
+		Severity:  model.SeverityInfo,
+		CreatedAt: time.Now().Truncate(time.Millisecond),
+	}
+	if len(broker.Topics) > 0 {
+		notification.Topic = broker.Topics[0]
+	}
+	return notification
+}
+
+func brokerPoolKey(broker model.Broker) mqtt.PoolKey {
+	return mqtt.PoolKey{
+		BrokerId: broker.Id,
+		Address:  broker.Address,
+		User:     broker.User,
+		Password: broker.Password,
+		Qos:      broker.Qos,
+		TLS:      brokerTLSOptions(broker),
+		V5:       broker.ProtocolVersion == model.MqttProtocolVersion5,
+	}
+}
+
+func brokerTLSOptions(broker model.Broker) mqtt.TLSOptions {
+	return mqtt.TLSOptions{
+		CaCert:     broker.CaCert,
+		ClientCert: broker.ClientCert,
+		ClientKey:  broker.ClientKey,
+		Insecure:   broker.Insecure,
+	}
 }
@@ -0,0 +1,105 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+const cleanupBatchSize = 1000
+
+func (this *Controller) startNotificationCleanup(ctx context.Context) error {
+	if this.config.NotificationCleanupInterval == "" || this.config.NotificationCleanupInterval == "-" {
+		return nil
+	}
+	interval, err := time.ParseDuration(this.config.NotificationCleanupInterval)
+	if err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				this.removeExpiredNotifications()
+				this.applyRetentionSettings()
+			}
+		}
+	}()
+	return nil
+}
+
+func (this *Controller) removeExpiredNotifications() {
+	for {
+		removed, err := this.db.RemoveExpiredNotifications(time.Now(), cleanupBatchSize)
+		if err != nil {
+			log.Println("ERROR: unable to remove expired notifications", err)
+			return
+		}
+		count := 0
+		for userId, ids := range removed {
+			count += len(ids)
+			this.notifyNotificationsRemoved(userId, ids)
+		}
+		if count < cleanupBatchSize {
+			return
+		}
+	}
+}
+
+func (this *Controller) applyRetentionSettings() {
+	settingsList, err := this.db.ListSettingsWithRetention()
+	if err != nil {
+		log.Println("ERROR: unable to list retention settings", err)
+		return
+	}
+	for _, settings := range settingsList {
+		for topic, days := range settings.RetentionDays {
+			if days <= 0 {
+				continue
+			}
+			olderThan := time.Now().AddDate(0, 0, -days)
+			for {
+				ids, err := this.db.RemoveNotificationsOlderThan(settings.UserId, topic, olderThan, cleanupBatchSize)
+				if err != nil {
+					log.Println("ERROR: unable to apply retention for user", settings.UserId, err)
+					break
+				}
+				this.notifyNotificationsRemoved(settings.UserId, ids)
+				if len(ids) < cleanupBatchSize {
+					break
+				}
+			}
+		}
+	}
+}
+
+func (this *Controller) notifyNotificationsRemoved(userId string, ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	if this.config.Debug {
+		log.Println("DEBUG: removed", len(ids), "notifications of user", userId)
+	}
+	go this.handleWsNotificationDelete(userId, ids)
+	go this.handleFCMNotificationDelete(userId, ids)
+}
@@ -18,7 +18,9 @@ package controller
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"os"
 	"sync"
 	"time"
 
@@ -38,21 +40,31 @@ type Controller struct {
 	sessionsMux           sync.Mutex
 	sessions              map[string][]*WsSession
 	platformMqttPublisher *mqtt.Publisher
+	mqttPool              *mqtt.Pool
 	firebaseClient        *messaging.Client
 	clientToken           *vaultjwt.OpenidToken
+	outbox                *outboxConfig // nil if the outbox is disabled
 }
 
 func New(config configuration.Config, db Persistence, ctx context.Context) (*Controller, error) {
 	var publisher *mqtt.Publisher
 	if config.PlatformMqttAddress != "" && config.PlatformMqttAddress != "-" {
-		var err error
-		publisher, err = mqtt.NewPublisher(context.Background(), config.PlatformMqttAddress, config.PlatformMqttUser,
-			config.PlatformMqttPw, config.MqttClientPrefix+uuid.NewString(), config.PlatformMqttQos, config.Debug)
+		tlsOptions, err := platformMqttTLSOptions(config)
+		if err != nil {
+			return nil, err
+		}
+		publisher, err = mqtt.NewTLSPublisher(context.Background(), config.PlatformMqttAddress, config.PlatformMqttUser,
+			config.PlatformMqttPw, config.MqttClientPrefix+uuid.NewString(), config.PlatformMqttQos, tlsOptions, config.Debug)
 		if err != nil {
 			log.Fatal(err.Error())
 		}
 	}
 
+	mqttPoolIdleTimeout, err := time.ParseDuration(config.MqttPoolIdleTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mqtt_pool_idle_timeout: %w", err)
+	}
+
 	var firebaseClient *messaging.Client
 
 	if config.FcmProjectId != "" && config.FcmIamId != "" {
@@ -75,25 +87,101 @@ func New(config configuration.Config, db Persistence, ctx context.Context) (*Con
 		sessionsMux:           sync.Mutex{},
 		sessions:              make(map[string][]*WsSession),
 		platformMqttPublisher: publisher,
+		mqttPool:              mqtt.NewPool(ctx, config.MqttClientPrefix, mqttPoolIdleTimeout, int(config.MqttPoolMaxConcurrency), config.Debug),
 		firebaseClient:        firebaseClient,
 		clientToken:           &vaultjwt.OpenidToken{},
 	}
 
+	err = c.startNotificationCleanup(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.startOutboxWorkers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.startScheduledDelivery(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.startMqttIngestion(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.startKafkaConsumer(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	return c, nil
 }
 
+func platformMqttTLSOptions(config configuration.Config) (result mqtt.TLSOptions, err error) {
+	result.CaCert, err = readOptionalFile(config.PlatformMqttCaCertFile)
+	if err != nil {
+		return result, err
+	}
+	result.ClientCert, err = readOptionalFile(config.PlatformMqttClientCertFile)
+	if err != nil {
+		return result, err
+	}
+	result.ClientKey, err = readOptionalFile(config.PlatformMqttClientKeyFile)
+	if err != nil {
+		return result, err
+	}
+	result.Insecure = config.PlatformMqttInsecure
+	return result, nil
+}
+
+func readOptionalFile(file string) (string, error) {
+	if file == "" || file == "-" {
+		return "", nil
+	}
+	content, err := os.ReadFile(file)
+	return string(content), err
+}
+
 type Persistence interface {
 	ListNotifications(userId string, options persistence.ListOptions, topics []model.Topic) (result []model.Notification, total int64, err error, errCode int)
 	ReadNotification(userId string, id string) (result model.Notification, err error, errCode int)
 	ReadNotificationByHash(userId string, hash [32]byte, notOlderThan time.Time) (result model.Notification, err error, errCode int)
 	SetNotification(notification model.Notification) (err error, errCode int)
 	RemoveNotifications(userId string, ids []string) (err error, errCode int)
+	UpsertNotificationByCorrelationKey(notification model.Notification) (result model.Notification, err error, errCode int)
+	DeferDelivery(delivery model.DeferredDelivery) error
+	EnqueueOutboxJob(job model.OutboxJob) error
+	ClaimOutboxJob(now time.Time, lease time.Duration) (job model.OutboxJob, found bool, err error)
+	SetOutboxJob(job model.OutboxJob) error
+	ListOutboxJobs(userId string, notificationId string) (result []model.OutboxJob, err error, errCode int)
+	AddDeliveryAttempt(attempt model.DeliveryAttempt) error
+	ListDeliveryAttempts(userId string, notificationId string) (result []model.DeliveryAttempt, err error, errCode int)
+	QueueEmailDigestEntry(entry model.EmailDigestEntry) error
+	ClaimDueEmailDigests(now time.Time, lease time.Duration, limit int64) (result []model.EmailDigest, err error)
+	CompleteEmailDigest(digestId string, sentAt time.Time) error
+	ReleaseEmailDigest(digestId string, retryAt time.Time) error
+	ClaimDueDeferredDeliveries(now time.Time, limit int64) (result []model.DeferredDelivery, err error)
+	ClaimDueNotifications(now time.Time, lease time.Duration, limit int64) (result []model.Notification, err error)
+	CompleteScheduledNotification(id string) error
+	IncrementNotificationOccurrences(userId string, id string, occurredAt time.Time) (result model.Notification, err error, errCode int)
+	ResolveNotification(userId string, correlationKey string, resolvedAt time.Time) (result model.Notification, err error, errCode int)
+	RemoveNotificationByCorrelationKey(userId string, correlationKey string) (result model.Notification, err error, errCode int)
+	CountNotifications(userId string, topics []model.Topic) (result model.NotificationCounts, err error, errCode int)
+	RemoveExpiredNotifications(now time.Time, limit int64) (removed map[string][]string, err error)
+	RemoveNotificationsOlderThan(userId string, topic model.Topic, olderThan time.Time, limit int64) (removed []string, err error)
+	SetNotificationsReadState(userId string, ids []string, options persistence.ListOptions, topics []model.Topic, isRead bool) (updatedIds []string, err error, errCode int)
 
 	ListBrokers(userId string, options persistence.ListOptions) (result []model.Broker, total int64, err error, errCode int)
 	ListEnabledBrokers(userId string) (result []model.Broker, err error)
 	ReadBroker(userId string, id string) (result model.Broker, err error, errCode int)
 	SetBroker(broker model.Broker) (err error, errCode int)
 	RemoveBrokers(userId string, ids []string) (err error, errCode int)
+	SetBrokerDeliverySuccess(id string, at time.Time, staleBefore time.Time) error
+	SetBrokerDeliveryError(id string, at time.Time, deliveryErr string) error
+	AddBrokerDeliveryFailure(id string, at time.Time, deliveryErr string, disableAfter int64) (disabled bool, err error)
 
 	ReadPlatformBroker(userId string) (platformBroker model.PlatformBroker, err error, errCode int)
 	SetPlatformBroker(platformBroker model.PlatformBroker) (err error, errCode int)
@@ -105,4 +193,5 @@ type Persistence interface {
 
 	ReadSettings(userId string) (result model.Settings, err error, errCode int)
 	SetSettings(settings model.Settings) (error, int)
+	ListSettingsWithRetention() (result []model.Settings, err error)
 }
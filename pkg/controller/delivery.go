@@ -0,0 +1,72 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"log"
+	"time"
+
+	"github.com/SENERGY-Platform/notifier/pkg/auth"
+	"github.com/SENERGY-Platform/notifier/pkg/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func (this *Controller) ListNotificationDeliveries(token auth.Token, id string) (result model.NotificationDeliveries, err error, errCode int) {
+	_, err, errCode = this.db.ReadNotification(token.GetUserId(), id) // checks existence and ownership
+	if err != nil {
+		return result, err, errCode
+	}
+	result.Attempts, err, errCode = this.db.ListDeliveryAttempts(token.GetUserId(), id)
+	if err != nil {
+		return result, err, errCode
+	}
+	result.Jobs, err, errCode = this.db.ListOutboxJobs(token.GetUserId(), id)
+	return
+}
+
+// recordDelivery stores the result of delivering the notification to the target.
+// messages without notification id (e.g. delete or read state events) are not recorded.
+func (this *Controller) recordDelivery(userId string, notificationId string, channel model.Channel, target string, deliveryErr error) {
+	if notificationId == "" {
+		return
+	}
+	attempt := model.DeliveryAttempt{
+		Id:             primitive.NewObjectID().Hex(),
+		UserId:         userId,
+		NotificationId: notificationId,
+		Channel:        channel,
+		Target:         target,
+		Success:        deliveryErr == nil,
+		Timestamp:      time.Now(),
+	}
+	if deliveryErr != nil {
+		attempt.Error = deliveryErr.Error()
+	}
+	err := this.db.AddDeliveryAttempt(attempt)
+	if err != nil {
+		log.Println("ERROR: unable to record delivery of notification", notificationId, err)
+	}
+}
+
+// fcmTokenTarget identifies a fcm token in delivery records without exposing it
+func fcmTokenTarget(token string) string {
+	const suffixLength = 8
+	if len(token) <= suffixLength {
+		return token
+	}
+	return "..." + token[len(token)-suffixLength:]
+}
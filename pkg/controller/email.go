@@ -19,10 +19,15 @@ package controller
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"html"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/SENERGY-Platform/notifier/pkg/auth"
 	"github.com/SENERGY-Platform/notifier/pkg/model"
@@ -57,10 +62,27 @@ func (s *SendRequest) Send(remoteAddress string) (messageId string, err error) {
 
 }
 
-func (this *Controller) handleEmailNotificationUpdate(token auth.Token, notification model.Notification) {
-	if len(token.Email) == 0 || !token.EmailVerified {
+// deliverEmail sends the notification immediately or adds it to the users next digest
+func (this *Controller) deliverEmail(token auth.Token, notification model.Notification, settings model.Settings) {
+	due, digest := settings.NextEmailDigest(time.Now())
+	if !digest {
+		this.enqueueDelivery(token, model.ChannelEmail, notification)
 		return
 	}
+	err := this.db.QueueEmailDigestEntry(model.EmailDigestEntry{
+		UserId:         token.GetUserId(),
+		NotificationId: notification.Id,
+		DueAt:          due,
+	})
+	if err != nil {
+		log.Println("ERROR: unable to queue notification", notification.Id, "for email digest", err)
+	}
+}
+
+func (this *Controller) handleEmailNotificationUpdate(token auth.Token, notification model.Notification) error {
+	if len(token.Email) == 0 || !token.EmailVerified {
+		return nil
+	}
 	email := SendRequest{
 		To: []FromTo{{
 			Email: token.Email,
@@ -71,8 +93,59 @@ func (this *Controller) handleEmailNotificationUpdate(token auth.Token, notifica
 		Subject: notification.Title,
 		Text:    notification.Message,
 	}
+	if notification.Link != "" || len(notification.Actions) > 0 {
+		email.Text, email.HTML = this.renderEmailBody(notification)
+	}
 	_, err := email.Send(this.config.MailpitHostPort)
+	this.recordDelivery(token.GetUserId(), notification.Id, model.ChannelEmail, token.Email, err)
 	if err != nil {
-		log.Println("ERROR: Sending Email failed: " + err.Error())
+		return errors.New("sending email failed: " + err.Error())
+	}
+	return nil
+}
+
+// renderEmailBody appends the link and the url actions to the message. Callback actions can not be triggered from an email and are omitted.
+func (this *Controller) renderEmailBody(notification model.Notification) (text string, htmlBody string) {
+	textBuilder := strings.Builder{}
+	htmlBuilder := strings.Builder{}
+	textBuilder.WriteString(notification.Message)
+	htmlBuilder.WriteString("<p>" + strings.ReplaceAll(html.EscapeString(notification.Message), "\n", "<br>") + "</p>")
+	links := emailLinks(notification)
+	if len(links) > 0 {
+		textBuilder.WriteString("\n")
+		htmlBuilder.WriteString("<p>")
+	}
+	for _, link := range links {
+		url := this.absoluteUiUrl(link.Url)
+		textBuilder.WriteString("\n" + link.Label + ": " + url)
+		htmlBuilder.WriteString(`<a href="` + html.EscapeString(url) + `" style="display:inline-block;padding:8px 16px;margin-right:8px;border:1px solid #888;border-radius:4px;text-decoration:none">` + html.EscapeString(link.Label) + `</a>`)
+	}
+	if len(links) > 0 {
+		htmlBuilder.WriteString("</p>")
+	}
+	return textBuilder.String(), htmlBuilder.String()
+}
+
+func emailLinks(notification model.Notification) []model.NotificationAction {
+	links := []model.NotificationAction{}
+	if notification.Link != "" {
+		links = append(links, model.NotificationAction{Label: "Open", Url: notification.Link})
+	}
+	for _, action := range notification.Actions {
+		if action.Url != "" {
+			links = append(links, action)
+		}
+	}
+	return links
+}
+
+func (this *Controller) absoluteUiUrl(link string) string {
+	if this.config.UiBaseUrl == "" {
+		return link
+	}
+	parsed, err := url.Parse(link)
+	if err == nil && parsed.IsAbs() {
+		return link
 	}
+	return strings.TrimSuffix(this.config.UiBaseUrl, "/") + "/" + strings.TrimPrefix(link, "/")
 }
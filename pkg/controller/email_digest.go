@@ -0,0 +1,173 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"html"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/SENERGY-Platform/notifier/pkg/model"
+)
+
+const emailDigestBatchSize = 100
+
+// an instance that does not finish sending a digest within the lease is considered dead and the digest is claimed again
+const emailDigestClaimLease = 5 * time.Minute
+
+// failed digests are retried after this backoff, doubled with each attempt up to emailDigestMaxRetryBackoff
+const emailDigestRetryBackoff = time.Minute
+const emailDigestMaxRetryBackoff = time.Hour
+
+func (this *Controller) sendDueEmailDigests() {
+	for {
+		digests, err := this.db.ClaimDueEmailDigests(time.Now(), emailDigestClaimLease, emailDigestBatchSize)
+		if err != nil {
+			log.Println("ERROR: unable to claim email digests", err)
+			return
+		}
+		for _, digest := range digests {
+			this.sendEmailDigest(digest)
+		}
+		if len(digests) < emailDigestBatchSize {
+			return
+		}
+	}
+}
+
+// sendEmailDigest sends one email with the notifications of the digest that still exist and are unread
+func (this *Controller) sendEmailDigest(digest model.EmailDigest) {
+	notifications := []model.Notification{}
+	for _, id := range digest.NotificationIds {
+		notification, err, errCode := this.db.ReadNotification(digest.UserId, id)
+		if errCode == http.StatusNotFound {
+			continue
+		}
+		if err != nil {
+			log.Println("ERROR: unable to read notification", id, "for email digest", err)
+			continue
+		}
+		if !notification.IsRead {
+			notifications = append(notifications, notification)
+		}
+	}
+	if len(notifications) == 0 {
+		this.completeEmailDigest(digest)
+		return
+	}
+	token, err := this.createInternalUserToken(digest.UserId)
+	if err != nil {
+		this.releaseEmailDigest(digest, err)
+		return
+	}
+	if len(token.Email) == 0 || !token.EmailVerified {
+		this.completeEmailDigest(digest)
+		return
+	}
+	email := SendRequest{
+		To: []FromTo{{
+			Email: token.Email,
+		}},
+		From: FromTo{
+			Email: this.config.EmailFrom,
+		},
+		Subject: emailDigestSubject(notifications),
+	}
+	email.Text, email.HTML = this.renderEmailDigest(notifications)
+	_, err = email.Send(this.config.MailpitHostPort)
+	for _, notification := range notifications {
+		this.recordDelivery(digest.UserId, notification.Id, model.ChannelEmail, token.Email, err)
+	}
+	if err != nil {
+		this.releaseEmailDigest(digest, err)
+		return
+	}
+	this.completeEmailDigest(digest)
+	if this.config.Debug {
+		log.Println("DEBUG: sent email digest", digest.Id, "with", len(notifications), "notifications to user", digest.UserId)
+	}
+}
+
+func (this *Controller) completeEmailDigest(digest model.EmailDigest) {
+	err := this.db.CompleteEmailDigest(digest.Id, time.Now())
+	if err != nil {
+		log.Println("ERROR: unable to complete email digest", digest.Id, err)
+	}
+}
+
+// releaseEmailDigest queues the entries again with a growing backoff, so that they are part of a later attempt
+func (this *Controller) releaseEmailDigest(digest model.EmailDigest, cause error) {
+	retryAt := time.Now().Add(outboxRetryDelay(digest.Attempts+1, emailDigestRetryBackoff, emailDigestMaxRetryBackoff))
+	log.Println("ERROR: sending email digest", digest.Id, "failed, retry at", retryAt.Format(time.RFC3339), cause)
+	err := this.db.ReleaseEmailDigest(digest.Id, retryAt)
+	if err != nil {
+		log.Println("ERROR: unable to release email digest", digest.Id, err)
+	}
+}
+
+func emailDigestSubject(notifications []model.Notification) string {
+	if len(notifications) == 1 {
+		return notifications[0].Title
+	}
+	return strconv.Itoa(len(notifications)) + " new notifications"
+}
+
+// renderEmailDigest lists the notifications grouped by topic, oldest first
+func (this *Controller) renderEmailDigest(notifications []model.Notification) (text string, htmlBody string) {
+	byTopic := map[model.Topic][]model.Notification{}
+	for _, notification := range notifications {
+		byTopic[notification.Topic] = append(byTopic[notification.Topic], notification)
+	}
+	topics := []model.Topic{}
+	for topic := range byTopic {
+		topics = append(topics, topic)
+	}
+	slices.Sort(topics)
+
+	textBuilder := strings.Builder{}
+	htmlBuilder := strings.Builder{}
+	for _, topic := range topics {
+		list := byTopic[topic]
+		slices.SortFunc(list, func(a, b model.Notification) int {
+			return a.CreatedAt.Compare(b.CreatedAt)
+		})
+		textBuilder.WriteString(topic + " (" + strconv.Itoa(len(list)) + ")\n")
+		htmlBuilder.WriteString("<h3>" + html.EscapeString(topic) + " (" + strconv.Itoa(len(list)) + ")</h3><ul>")
+		for _, notification := range list {
+			created := notification.CreatedAt.UTC().Format("2006-01-02 15:04 UTC")
+			textBuilder.WriteString("- " + notification.Title + " (" + created + ")\n")
+			htmlBuilder.WriteString("<li><b>" + html.EscapeString(notification.Title) + "</b> <small>" + created + "</small>")
+			if notification.Message != "" {
+				textBuilder.WriteString("  " + strings.ReplaceAll(notification.Message, "\n", "\n  ") + "\n")
+				htmlBuilder.WriteString("<br>" + strings.ReplaceAll(html.EscapeString(notification.Message), "\n", "<br>"))
+			}
+			for _, link := range emailLinks(notification) {
+				url := this.absoluteUiUrl(link.Url)
+				textBuilder.WriteString("  " + link.Label + ": " + url + "\n")
+				htmlBuilder.WriteString(`<br><a href="` + html.EscapeString(url) + `">` + html.EscapeString(link.Label) + `</a>`)
+			}
+			htmlBuilder.WriteString("</li>")
+		}
+		textBuilder.WriteString("\n")
+		htmlBuilder.WriteString("</ul>")
+	}
+	return textBuilder.String(), htmlBuilder.String()
+}
@@ -19,6 +19,7 @@ package controller
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"log"
 	"time"
 
@@ -43,19 +44,18 @@ func (this *Controller) DeleteFcmToken(token auth.Token, fcmToken string) (err e
 	})
 }
 
-func (this *Controller) handleFCMNotificationUpdate(userId string, notification model.Notification) {
+func (this *Controller) handleFCMNotificationUpdate(userId string, notification model.Notification) error {
 	if this.firebaseClient == nil {
 		log.Println("WARNING: Skipping FCM messaging since client not configured")
-		return
+		return nil
 	}
 
 	tokens, err := this.getValidTokens(userId)
 	if err != nil {
-		log.Println("ERROR:", err.Error())
-		return
+		return err
 	}
 	if tokens == nil || len(tokens) == 0 {
-		return
+		return nil
 	}
 
 	encoded, _ := json.Marshal(notification)
@@ -68,35 +68,94 @@ func (this *Controller) handleFCMNotificationUpdate(userId string, notification
 		},
 	}
 
+	if notification.Link != "" {
+		message.Data["link"] = notification.Link
+	}
+	if len(notification.Actions) > 0 {
+		encodedActions, _ := json.Marshal(notification.Actions)
+		message.Data["actions"] = string(encodedActions)
+	}
+	if len(notification.Metadata) > 0 {
+		encodedMetadata, _ := json.Marshal(notification.Metadata)
+		message.Data["metadata"] = string(encodedMetadata)
+	}
+
 	if !notification.IsRead {
 		message.Notification = &messaging.Notification{
 			Title: notification.Title,
 			Body:  notification.Message,
 		}
+		category := ""
+		if len(notification.Actions) > 0 {
+			category = fcmActionCategory
+		}
+		priority, notificationPriority, interruptionLevel := fcmPriorities(notification.Severity)
 		message.Android = &messaging.AndroidConfig{
 			Notification: &messaging.AndroidNotification{
-				Title: notification.Title,
-				Body:  notification.Message,
-				Tag:   notification.Id,
+				Title:       notification.Title,
+				Body:        notification.Message,
+				Tag:         notification.Id,
+				Priority:    notificationPriority,
+				ClickAction: category,
 			},
-			Priority: "high",
+			Priority: priority,
 		}
 		message.APNS = &messaging.APNSConfig{
 			Headers: map[string]string{
 				"apns-collapse-id": notification.Id,
 			},
+			Payload: &messaging.APNSPayload{
+				Aps: &messaging.Aps{
+					Category: category,
+					CustomData: map[string]interface{}{
+						"interruption-level": interruptionLevel,
+					},
+				},
+			},
 		}
 	}
 
 	responses, err := this.firebaseClient.SendEachForMulticast(context.Background(), message)
 	if err != nil {
-		log.Println("ERROR:", err.Error())
-		return
+		for _, token := range tokens {
+			this.recordDelivery(userId, notification.Id, model.ChannelFcm, fcmTokenTarget(token), err)
+		}
+		return err
+	}
+	failed := this.handleFcmResponses(responses, tokens, userId, notification.Id)
+	if failed > 0 && responses.SuccessCount == 0 {
+		return fmt.Errorf("fcm delivery failed for %d tokens", failed) // retry only if no device was reached, to avoid duplicate pushes
+	}
+	return nil
+}
+
+// fcmActionCategory is used as android click_action and apns category of notifications with actions.
+// Apps register buttons for this category and read the actions from the data payload.
+const fcmActionCategory = "NOTIFICATION_WITH_ACTIONS"
+
+// fcmPriorities maps the severity to the android message priority, the android notification priority and the apns interruption level
+func fcmPriorities(severity model.Severity) (priority string, notificationPriority messaging.AndroidNotificationPriority, interruptionLevel string) {
+	switch severity {
+	case model.SeverityCritical:
+		return "high", messaging.PriorityMax, "critical"
+	case model.SeverityError:
+		return "high", messaging.PriorityHigh, "time-sensitive"
+	case model.SeverityWarning:
+		return "high", messaging.PriorityDefault, "active"
+	default:
+		return "normal", messaging.PriorityDefault, "active"
 	}
-	this.handleFcmResponses(responses, tokens, userId)
 }
 
 func (this *Controller) handleFCMNotificationDelete(userId string, ids []string) {
+	this.sendFcmDataMessage(userId, model.WsUpdateDeleteManyType, ids)
+}
+
+func (this *Controller) handleFCMNotificationReadState(userId string, state model.NotificationReadState) {
+	this.sendFcmDataMessage(userId, model.WsUpdateReadStateManyType, state)
+}
+
+func (this *Controller) sendFcmDataMessage(userId string, messageType string, payload interface{}) {
 	if this.firebaseClient == nil {
 		log.Println("WARNING: Skipping FCM messaging since client not configured")
 		return
@@ -111,12 +170,12 @@ func (this *Controller) handleFCMNotificationDelete(userId string, ids []string)
 		return
 	}
 
-	encoded, _ := json.Marshal(ids)
+	encoded, _ := json.Marshal(payload)
 
 	responses, err := this.firebaseClient.SendEachForMulticast(context.Background(), &messaging.MulticastMessage{
 		Tokens: tokens,
 		Data: map[string]string{
-			"type":    model.WsUpdateDeleteManyType,
+			"type":    messageType,
 			"payload": string(encoded),
 		},
 	})
@@ -124,10 +183,14 @@ func (this *Controller) handleFCMNotificationDelete(userId string, ids []string)
 		log.Println("ERROR:", err.Error())
 		return
 	}
-	this.handleFcmResponses(responses, tokens, userId)
+	this.handleFcmResponses(responses, tokens, userId, "")
 }
 
-func (this *Controller) handleFcmResponses(responses *messaging.BatchResponse, tokens []string, userId string) {
+// handleFcmResponses removes unknown tokens, records the deliveries of the notification and returns the number of failed deliveries to known tokens
+func (this *Controller) handleFcmResponses(responses *messaging.BatchResponse, tokens []string, userId string, notificationId string) (failed int) {
+	for i := range responses.Responses {
+		this.recordDelivery(userId, notificationId, model.ChannelFcm, fcmTokenTarget(tokens[i]), responses.Responses[i].Error)
+	}
 	if responses.FailureCount > 0 {
 		for i := range responses.Responses {
 			if responses.Responses[i].Error != nil {
@@ -140,11 +203,13 @@ func (this *Controller) handleFcmResponses(responses *messaging.BatchResponse, t
 						log.Println("ERROR: could not delete outdated token for user " + userId + ": " + err.Error())
 					}
 				} else {
+					failed++
 					log.Println("ERROR: sending fcm notification ", responses.Responses[i].MessageID, responses.Responses[i].Error.Error())
 				}
 			}
 		}
 	}
+	return failed
 }
 
 func (this *Controller) getValidTokens(userId string) (tokens []string, err error) {
@@ -0,0 +1,45 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"testing"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/SENERGY-Platform/notifier/pkg/model"
+)
+
+func TestFcmPriorities(t *testing.T) {
+	cases := []struct {
+		severity             model.Severity
+		priority             string
+		notificationPriority messaging.AndroidNotificationPriority
+		interruptionLevel    string
+	}{
+		{"", "normal", messaging.PriorityDefault, "active"},
+		{model.SeverityInfo, "normal", messaging.PriorityDefault, "active"},
+		{model.SeverityWarning, "high", messaging.PriorityDefault, "active"},
+		{model.SeverityError, "high", messaging.PriorityHigh, "time-sensitive"},
+		{model.SeverityCritical, "high", messaging.PriorityMax, "critical"},
+	}
+	for _, c := range cases {
+		priority, notificationPriority, interruptionLevel := fcmPriorities(c.severity)
+		if priority != c.priority || notificationPriority != c.notificationPriority || interruptionLevel != c.interruptionLevel {
+			t.Errorf("severity %q: unexpected priorities %v %v %v", c.severity, priority, notificationPriority, interruptionLevel)
+		}
+	}
+}
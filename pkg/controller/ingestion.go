@@ -0,0 +1,149 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SENERGY-Platform/notifier/pkg/model"
+	"github.com/SENERGY-Platform/notifier/pkg/mqtt"
+	"github.com/google/uuid"
+)
+
+const ingestionUserIdPlaceholder = "{userId}"
+
+// server errors while ingesting, like an unavailable database, are retried with this backoff
+const ingestionRetryBackoff = time.Second
+const ingestionMaxRetryBackoff = time.Minute
+
+// startMqttIngestion subscribes to the ingestion topic of the platform broker and creates the received notifications
+func (this *Controller) startMqttIngestion(ctx context.Context) error {
+	if this.config.MqttIngestionTopic == "" || this.config.MqttIngestionTopic == "-" {
+		return nil
+	}
+	if this.platformMqttPublisher == nil {
+		return errors.New("mqtt ingestion requires the platform broker")
+	}
+	tlsOptions, err := platformMqttTLSOptions(this.config)
+	if err != nil {
+		return err
+	}
+	topic := strings.ReplaceAll(this.config.MqttIngestionTopic, ingestionUserIdPlaceholder, "+")
+	if this.config.MqttIngestionShareGroup != "" {
+		topic = "$share/" + this.config.MqttIngestionShareGroup + "/" + topic
+	}
+	_, err = mqtt.NewSubscriber(ctx, this.config.PlatformMqttAddress, this.config.PlatformMqttUser, this.config.PlatformMqttPw,
+		this.config.MqttClientPrefix+uuid.NewString(), this.config.PlatformMqttQos, tlsOptions, topic, func(topic string, payload []byte) {
+			this.ingestNotification(ctx, topic, payload)
+		}, this.config.Debug)
+	return err
+}
+
+// ingestNotification creates the notification of the message. invalid messages are published to the error topic,
+// server errors are retried until the notification is created, the message is acknowledged after this returned.
+func (this *Controller) ingestNotification(ctx context.Context, topic string, payload []byte) {
+	err, code := this.createIngestedNotification(topic, payload)
+	for attempt := 1; err != nil && code >= http.StatusInternalServerError; attempt++ {
+		log.Println("WARNING: unable to ingest notification from", topic, "-> retry:", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(outboxRetryDelay(attempt, ingestionRetryBackoff, ingestionMaxRetryBackoff)):
+		}
+		err, code = this.createIngestedNotification(topic, payload)
+	}
+	if err == nil {
+		return
+	}
+	log.Println("WARNING: unable to ingest notification from", topic, err)
+	if this.config.MqttIngestionErrorTopic == "" || this.config.MqttIngestionErrorTopic == "-" {
+		return
+	}
+	message, _ := json.Marshal(model.IngestionError{
+		Topic:   topic,
+		Payload: string(payload),
+		Error:   err.Error(),
+		Code:    code,
+	})
+	err = this.platformMqttPublisher.Publish(this.config.MqttIngestionErrorTopic, string(message))
+	if err != nil {
+		log.Println("ERROR: unable to publish ingestion error", err)
+	}
+}
+
+func (this *Controller) createIngestedNotification(topic string, payload []byte) (err error, errCode int) {
+	ingestion, err := decodeNotificationIngestion(payload)
+	if err != nil {
+		return err, http.StatusBadRequest
+	}
+	userId, ok := ingestionTopicUserId(this.config.MqttIngestionTopic, topic)
+	if ok {
+		if ingestion.UserId != "" && ingestion.UserId != userId {
+			return errors.New("user id of payload does not match the topic"), http.StatusBadRequest
+		}
+		ingestion.UserId = userId
+	}
+	return this.createNotificationFromIngestion(ingestion)
+}
+
+// decodeNotificationIngestion is used for notifications received over mqtt and kafka
+func decodeNotificationIngestion(payload []byte) (ingestion model.NotificationIngestion, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&ingestion)
+	if err != nil {
+		return ingestion, fmt.Errorf("invalid notification: %w", err)
+	}
+	if ingestion.Title == "" {
+		return ingestion, errors.New("missing title")
+	}
+	return ingestion, nil
+}
+
+func (this *Controller) createNotificationFromIngestion(ingestion model.NotificationIngestion) (err error, errCode int) {
+	if ingestion.UserId == "" {
+		return errors.New("missing user id"), http.StatusBadRequest
+	}
+	_, err, errCode = this.CreateNotification(nil, ingestion.Notification, ingestion.IgnoreDuplicatesWithinSeconds, ingestion.PushDuplicates)
+	return err, errCode
+}
+
+// ingestionTopicUserId returns the topic level matching the user id placeholder of the pattern
+func ingestionTopicUserId(pattern string, topic string) (userId string, ok bool) {
+	patternLevels := strings.Split(pattern, "/")
+	topicLevels := strings.Split(topic, "/")
+	for i, level := range patternLevels {
+		if level == "#" {
+			return userId, ok
+		}
+		if i >= len(topicLevels) {
+			return "", false
+		}
+		if level == ingestionUserIdPlaceholder {
+			userId, ok = topicLevels[i], topicLevels[i] != ""
+		}
+	}
+	return userId, ok
+}
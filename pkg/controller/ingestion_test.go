@@ -0,0 +1,41 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import "testing"
+
+func TestIngestionTopicUserId(t *testing.T) {
+	cases := []struct {
+		pattern string
+		topic   string
+		userId  string
+		ok      bool
+	}{
+		{"notifier/create/{userId}", "notifier/create/user1", "user1", true},
+		{"notifier/{userId}/create", "notifier/user1/create", "user1", true},
+		{"notifier/{userId}/#", "notifier/user1/a/b", "user1", true},
+		{"notifier/create", "notifier/create", "", false},
+		{"notifier/create/{userId}", "notifier/create", "", false},
+		{"notifier/create/{userId}", "notifier/create/", "", false},
+	}
+	for _, c := range cases {
+		userId, ok := ingestionTopicUserId(c.pattern, c.topic)
+		if userId != c.userId || ok != c.ok {
+			t.Error(c.pattern, c.topic, userId, ok)
+		}
+	}
+}
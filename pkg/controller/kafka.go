@@ -0,0 +1,51 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/SENERGY-Platform/notifier/pkg/kafka"
+)
+
+// startKafkaConsumer creates notifications from the kafka topic. Offsets are committed after the notification is persisted
+// or the message is moved to the dead letter topic.
+func (this *Controller) startKafkaConsumer(ctx context.Context) error {
+	if this.config.KafkaUrl == "" || this.config.KafkaUrl == "-" {
+		return nil
+	}
+	retryInterval, err := time.ParseDuration(this.config.KafkaRetryInterval)
+	if err != nil {
+		return err
+	}
+	return kafka.NewConsumer(ctx, this.config.KafkaUrl, this.config.KafkaConsumerGroup, this.config.KafkaNotificationTopic, retryInterval,
+		this.config.KafkaMaxRetries, this.config.KafkaDeadLetterTopic, this.consumeKafkaNotification)
+}
+
+func (this *Controller) consumeKafkaNotification(message []byte) error {
+	ingestion, err := decodeNotificationIngestion(message)
+	errCode := http.StatusBadRequest
+	if err == nil {
+		err, errCode = this.createNotificationFromIngestion(ingestion)
+	}
+	if err != nil && errCode < http.StatusInternalServerError {
+		return kafka.Permanent(err) // invalid notification or unknown user, retrying does not help
+	}
+	return err
+}
@@ -17,66 +17,154 @@
 package controller
 
 import (
-	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/SENERGY-Platform/notifier/pkg/model"
 	"github.com/SENERGY-Platform/notifier/pkg/mqtt"
-	"github.com/google/uuid"
+	"github.com/eclipse/paho.golang/paho"
 	"log"
 	"net/http"
+	"slices"
+	"sync"
+	"time"
 )
 
-func (this *Controller) handleMqttNotificationUpdate(userId string, notification model.Notification) {
-	go this.handlerMqttPlatformBroker(userId, notification)
-	brokers, err := this.db.ListEnabledBrokers(userId)
+func (this *Controller) handleMqttNotificationUpdate(userId string, notification model.Notification, attempt int, skipTargets []string) (delivered []string, err error) {
+	return this.handleMqttPublish(userId, &notification, notification, attempt, skipTargets)
+}
+
+// mqttEventSubtopic is appended to the broker topic for events without notification, like read state changes,
+// so that consumers of the notification topic only receive notifications and retained notifications are not replaced
+const mqttEventSubtopic = "/events"
+
+func (this *Controller) handleMqttNotificationReadState(userId string, state model.NotificationReadState) {
+	_, err := this.handleMqttPublish(userId, nil, model.EventMessage{
+		Type:    model.WsUpdateReadStateManyType,
+		Payload: state,
+	}, 1, nil)
 	if err != nil {
 		log.Println("ERROR:", err.Error())
-		return
 	}
-	for _, broker := range brokers {
+}
+
+// handleMqttPublish publishes the payload to the platform broker and all enabled brokers of the user.
+// the returned error joins the errors of all brokers. deliveries are recorded if a notification is given.
+// brokers with topic filters or topic templates only receive notifications, not events without notification.
+// targets in skipTargets are not published to; the successfully published targets are returned,
+// so that retries do not publish duplicates to brokers that already received the payload.
+// failures of retries (attempt > 1) do not count towards disabling the broker, see updateBrokerHealth.
+func (this *Controller) handleMqttPublish(userId string, notification *model.Notification, payload interface{}, attempt int, skipTargets []string) (delivered []string, err error) {
+	notificationId := ""
+	if notification != nil {
+		notificationId = notification.Id
+	}
+	enabledBrokers, err := this.db.ListEnabledBrokers(userId)
+	if err != nil {
+		return nil, err
+	}
+	brokers := []model.Broker{}
+	for _, broker := range enabledBrokers {
+		if slices.Contains(skipTargets, broker.Id) {
+			continue
+		}
+		if notification == nil && (len(broker.Topics) > 0 || broker.TopicIsTemplate()) {
+			continue
+		}
+		if notification == nil || broker.AcceptsTopic(notification.Topic) {
+			brokers = append(brokers, broker)
+		}
+	}
+	errs := make([]error, len(brokers)+1)
+	wg := sync.WaitGroup{}
+	if !slices.Contains(skipTargets, model.DeliveryTargetPlatformBroker) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[0] = this.handlerMqttPlatformBroker(userId, notification, payload)
+		}()
+	}
+	for i, broker := range brokers {
 		broker := broker // thread safety
+		wg.Add(1)
 		go func() {
-			ctx, cancel := context.WithCancel(context.Background())
-			defer cancel()
-			publisher, err := mqtt.NewPublisher(ctx, broker.Address, broker.User, broker.Password, this.config.MqttClientPrefix+uuid.NewString(),
-				broker.Qos, this.config.Debug)
+			defer wg.Done()
+			message, ok, err := broker.MqttPayloadOptions.Render(notification, payload)
+			if err == nil && !ok {
+				return // the payload format does not publish this event
+			}
+			topic, retain := broker.Topic+mqttEventSubtopic, false
+			if err == nil && notification != nil {
+				retain = broker.Retain
+				topic, err = broker.RenderTopic(*notification)
+			}
+			if err == nil {
+				err = this.mqttPool.Publish(brokerPoolKey(broker), mqtt.Message{
+					Topic:          topic,
+					Payload:        message,
+					Retain:         retain,
+					ContentType:    broker.ContentType(),
+					UserProperties: mqttUserProperties(notification),
+					MessageExpiry:  broker.MessageExpirySeconds,
+				})
+				this.updateBrokerHealth(broker, err, attempt <= 1) // render errors are configuration errors and do not count as broker failures
+			}
+			this.recordDelivery(userId, notificationId, model.ChannelMqtt, broker.Id, err)
 			if err != nil {
-				log.Println("ERROR:", err.Error())
-				return
+				errs[i+1] = fmt.Errorf("broker %s: %w", broker.Id, err)
 			}
-			publishMqtt(publisher, broker.Topic, notification)
 		}()
 	}
+	wg.Wait()
+	if errs[0] == nil && !slices.Contains(skipTargets, model.DeliveryTargetPlatformBroker) {
+		delivered = append(delivered, model.DeliveryTargetPlatformBroker)
+	}
+	for i, broker := range brokers {
+		if errs[i+1] == nil {
+			delivered = append(delivered, broker.Id)
+		}
+	}
+	return delivered, errors.Join(errs...)
 }
 
-func (this *Controller) handlerMqttPlatformBroker(userId string, notification model.Notification) {
+func (this *Controller) handlerMqttPlatformBroker(userId string, notification *model.Notification, payload interface{}) error {
 	platformBroker, err, errCode := this.db.ReadPlatformBroker(userId)
 	if err != nil {
 		if errCode == http.StatusNotFound {
-			return
+			return nil
 		}
-		log.Println("Could not publish to platform broker", err)
-		return
+		return fmt.Errorf("could not publish to platform broker: %w", err)
 	}
 	if !platformBroker.Enabled {
-		return
+		return nil
+	}
+	message, ok, err := platformBroker.MqttPayloadOptions.Render(notification, payload)
+	if err == nil && !ok {
+		return nil
+	}
+	if err == nil && this.platformMqttPublisher == nil {
+		err = errors.New("could not publish: publisher nil")
+	}
+	topic, retain := this.config.PlatformMqttBasetopic+"/"+userId, platformBroker.Retain
+	if notification == nil {
+		topic, retain = topic+mqttEventSubtopic, false
 	}
-	publishMqtt(this.platformMqttPublisher, this.config.PlatformMqttBasetopic+"/"+userId, notification)
+	if err == nil {
+		err = this.platformMqttPublisher.PublishWithRetain(topic, message, retain)
+	}
+	if notification != nil {
+		this.recordDelivery(userId, notification.Id, model.ChannelMqtt, model.DeliveryTargetPlatformBroker, err)
+	}
+	return err
 }
 
-func publishMqtt(publisher *mqtt.Publisher, topic string, notification model.Notification) {
-	if publisher == nil {
-		log.Println("Could not publish: publisher nil")
-		return
+// mqttUserProperties lets MQTT v5 consumers route notifications without parsing the payload
+func mqttUserProperties(notification *model.Notification) []paho.UserProperty {
+	if notification == nil {
+		return nil
 	}
-	bytes, err := json.Marshal(notification)
-	if err != nil {
-		log.Println("ERROR:", err.Error())
-		return
-	}
-	err = publisher.Publish(topic, string(bytes))
-	if err != nil {
-		log.Println("ERROR:", err.Error())
-		return
+	return []paho.UserProperty{
+		{Key: "notification_id", Value: notification.Id},
+		{Key: "topic", Value: string(notification.Topic)},
+		{Key: "created_at", Value: notification.CreatedAt.Format(time.RFC3339Nano)},
 	}
 }
@@ -19,6 +19,7 @@ package controller
 import (
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"slices"
 	"time"
@@ -31,21 +32,53 @@ import (
 
 func (this *Controller) ListNotifications(token auth.Token, options persistence.ListOptions, channel model.Channel) (result model.NotificationList, err error, errCode int) {
 	result.Limit, result.Offset = options.Limit, options.Offset
-	topics := []model.Topic(append(model.AllTopics(), model.TopicUnknown))
-	if len(channel) > 0 {
-		if !slices.Contains(model.AllChannels(), channel) {
-			return result, fmt.Errorf("unknown channel %s", channel), http.StatusBadRequest
-		}
-		settings, err, code := this.GetSettings(token)
+	for key := range options.Labels {
+		err = model.ValidateMetadataKey(key)
 		if err != nil {
-			return result, err, code
+			return result, err, http.StatusBadRequest
+		}
+	}
+	for _, topic := range options.Topics {
+		if !slices.Contains(append(model.AllTopics(), model.TopicUnknown), topic) {
+			return result, fmt.Errorf("unknown topic %s", topic), http.StatusBadRequest
 		}
-		topics = settings.ChannelTopicConfig[channel]
+	}
+	topics, err, errCode := this.getChannelTopics(token.GetUserId(), channel)
+	if err != nil {
+		return result, err, errCode
 	}
 	result.Notifications, result.Total, err, errCode = this.db.ListNotifications(token.GetUserId(), options, topics)
+	if err == nil && options.UseCursor && options.Limit > 0 && len(result.Notifications) == options.Limit {
+		last := result.Notifications[len(result.Notifications)-1]
+		result.NextCursor = persistence.EncodeCursor(last.CreatedAt, last.Id)
+	}
 	return
 }
 
+func (this *Controller) CountNotifications(token auth.Token, channel model.Channel) (result model.NotificationCounts, err error, errCode int) {
+	topics, err, errCode := this.getChannelTopics(token.GetUserId(), channel)
+	if err != nil {
+		return result, err, errCode
+	}
+	return this.db.CountNotifications(token.GetUserId(), topics)
+}
+
+// getChannelTopics returns the topics the user has enabled for the channel, or all topics if no channel is given
+func (this *Controller) getChannelTopics(userId string, channel model.Channel) (topics []model.Topic, err error, errCode int) {
+	topics = append(model.AllTopics(), model.TopicUnknown)
+	if len(channel) == 0 {
+		return topics, nil, http.StatusOK
+	}
+	if !slices.Contains(model.AllChannels(), channel) {
+		return nil, fmt.Errorf("unknown channel %s", channel), http.StatusBadRequest
+	}
+	settings, err, errCode := this.getSettingsWithDefaults(userId)
+	if err != nil {
+		return nil, err, errCode
+	}
+	return settings.ChannelTopicConfig[channel], nil, http.StatusOK
+}
+
 func (this *Controller) ReadNotification(token auth.Token, id string) (result model.Notification, err error, errCode int) {
 	result, err, errCode = this.db.ReadNotification(token.GetUserId(), id)
 	if err != nil {
@@ -54,25 +87,64 @@ func (this *Controller) ReadNotification(token auth.Token, id string) (result mo
 	return result, nil, http.StatusOK
 }
 
+// SetNotification replaces the notification of the user. Fields managed by the server are kept,
+// as are omitted optional fields, so that clients unaware of them (e.g. marking as read) do not reset them.
 func (this *Controller) SetNotification(token auth.Token, notification model.Notification) (result model.Notification, err error, errCode int) {
-	_, err, errCode = this.db.ReadNotification(token.GetUserId(), notification.Id) // Check existence before set, this already checks for user id
+	if len(notification.Severity) > 0 && !slices.Contains(model.AllSeverities(), notification.Severity) {
+		return result, errors.New("the specified severity is not allowed"), http.StatusBadRequest
+	}
+	err = notification.ValidateLinks()
+	if err != nil {
+		return result, err, http.StatusBadRequest
+	}
+	for key := range notification.Metadata {
+		err = model.ValidateMetadataKey(key)
+		if err != nil {
+			return result, err, http.StatusBadRequest
+		}
+	}
+	existing, err, errCode := this.db.ReadNotification(token.GetUserId(), notification.Id) // Check existence before set, this already checks for user id
 	if err != nil {
 		return model.Notification{}, err, errCode
 	}
+	notification.DeliverAt = existing.DeliverAt // scheduling is only possible on creation
+	notification.ExpiresAt = existing.ExpiresAt
+	notification.CorrelationKey = existing.CorrelationKey
+	notification.Occurrences = existing.Occurrences
+	notification.LastOccurredAt = existing.LastOccurredAt
+	notification.ResolvedAt = existing.ResolvedAt
+	if len(notification.Severity) == 0 {
+		notification.Severity = existing.Severity
+	}
+	if len(notification.Severity) == 0 {
+		notification.Severity = model.SeverityInfo
+	}
+	if notification.Link == "" {
+		notification.Link = existing.Link
+	}
+	if notification.Actions == nil {
+		notification.Actions = existing.Actions
+	}
+	if notification.Metadata == nil {
+		notification.Metadata = existing.Metadata
+	}
 	settings, err, errCode := this.getSettings(token.GetUserId())
 	if err != nil {
 		return model.Notification{}, err, errCode
 	}
 	err, errCode = this.db.SetNotification(notification)
-	if err == nil {
-		this.handleUpdate(notification, token, settings)
+	if err == nil && notification.DeliverAt == nil {
+		this.handleUpdate(notification, token, settings) // scheduled notifications are delivered by the scheduler
 	}
 	return notification, err, errCode
 }
 
-func (this *Controller) CreateNotification(token *auth.Token, notification model.Notification, ignoreDuplicatesWithinSeconds *int64) (result model.Notification, err error, errCode int) {
+func (this *Controller) CreateNotification(token *auth.Token, notification model.Notification, ignoreDuplicatesWithinSeconds *int64, pushDuplicates bool) (result model.Notification, err error, errCode int) {
 	if token == nil { //internal access
 		token, err = this.createInternalUserToken(notification.UserId)
+		if errors.Is(err, ErrUserNotFound) {
+			return model.Notification{}, err, http.StatusNotFound
+		}
 		if err != nil {
 			return model.Notification{}, fmt.Errorf("unable to get user info"), http.StatusInternalServerError
 		}
@@ -86,11 +158,27 @@ func (this *Controller) CreateNotification(token *auth.Token, notification model
 	if !slices.Contains(append(model.AllTopics(), model.TopicUnknown), notification.Topic) {
 		return result, errors.New("the specified topic is not allowed"), http.StatusBadRequest
 	}
+	if len(notification.Severity) == 0 {
+		notification.Severity = model.SeverityInfo
+	}
+	if !slices.Contains(model.AllSeverities(), notification.Severity) {
+		return result, errors.New("the specified severity is not allowed"), http.StatusBadRequest
+	}
+	err = notification.ValidateLinks()
+	if err != nil {
+		return result, err, http.StatusBadRequest
+	}
+	for key := range notification.Metadata {
+		err = model.ValidateMetadataKey(key)
+		if err != nil {
+			return result, err, http.StatusBadRequest
+		}
+	}
 	if ignoreDuplicatesWithinSeconds != nil {
 		notOlderThan := time.Unix(time.Now().Unix()-*ignoreDuplicatesWithinSeconds, 0)
 		existing, err, code := this.db.ReadNotificationByHash(token.GetUserId(), notification.Hash(), notOlderThan)
 		if err == nil {
-			return existing, err, code
+			return this.handleDuplicate(*token, existing, pushDuplicates)
 		}
 		if code != http.StatusNotFound {
 			return existing, err, code
@@ -101,10 +189,32 @@ func (this *Controller) CreateNotification(token *auth.Token, notification model
 	if notification.CreatedAt.Before(time.UnixMilli(0)) {
 		notification.CreatedAt = time.Now().Truncate(time.Millisecond)
 	}
+	if notification.DeliverAt != nil && notification.DeliverAt.After(time.Now()) {
+		if notification.CorrelationKey != "" {
+			return model.Notification{}, errors.New("deliver_at can not be combined with correlation_key"), http.StatusBadRequest
+		}
+		// persisted hidden; the scheduler delivers it with created_at = deliver_at
+		deliverAt := notification.DeliverAt.Truncate(time.Millisecond)
+		notification.DeliverAt, notification.CreatedAt = &deliverAt, deliverAt
+		err, errCode = this.db.SetNotification(notification)
+		return notification, err, errCode
+	}
+	notification.DeliverAt = nil
 	settings, err, errCode := this.getSettings(token.GetUserId())
 	if err != nil {
 		return model.Notification{}, err, errCode
 	}
+	if notification.CorrelationKey != "" {
+		// replaces the existing notification with the same key, which is delivered again like a new one
+		notification.IsRead = false
+		notification.ResolvedAt = nil
+		notification.Occurrences = 0
+		result, err, errCode = this.db.UpsertNotificationByCorrelationKey(notification)
+		if err == nil {
+			this.handleCreate(result, *token, settings)
+		}
+		return result, err, errCode
+	}
 	err, errCode = this.db.SetNotification(notification)
 	if err == nil {
 		this.handleCreate(notification, *token, settings)
@@ -112,6 +222,58 @@ func (this *Controller) CreateNotification(token *auth.Token, notification model
 	return notification, err, errCode
 }
 
+// handleDuplicate counts the suppressed duplicate on the existing notification.
+// if requested, the updated notification is pushed to websocket and mqtt, so that clients may show the new count.
+func (this *Controller) handleDuplicate(token auth.Token, existing model.Notification, push bool) (result model.Notification, err error, errCode int) {
+	result, err, errCode = this.db.IncrementNotificationOccurrences(token.GetUserId(), existing.Id, time.Now().Truncate(time.Millisecond))
+	if err != nil || !push {
+		return result, err, errCode
+	}
+	settings, err, errCode := this.getSettings(token.GetUserId())
+	if err != nil {
+		return result, err, errCode
+	}
+	if settings.ChannelEnabled(model.ChannelWebsocket, result) {
+		go this.handleWsNotificationUpdate(token.GetUserId(), result)
+	}
+	if settings.ChannelEnabled(model.ChannelMqtt, result) {
+		this.enqueueDelivery(token, model.ChannelMqtt, result)
+	}
+	return result, nil, http.StatusOK
+}
+
+func (this *Controller) ResolveNotification(token *auth.Token, request model.NotificationResolveRequest) (result model.Notification, err error, errCode int) {
+	if token == nil { //internal access
+		token, err = this.createInternalUserToken(request.UserId)
+		if errors.Is(err, ErrUserNotFound) {
+			return result, err, http.StatusNotFound
+		}
+		if err != nil {
+			return result, fmt.Errorf("unable to get user info"), http.StatusInternalServerError
+		}
+	}
+	if request.CorrelationKey == "" {
+		return result, errors.New("missing correlation_key"), http.StatusBadRequest
+	}
+	if request.Delete {
+		result, err, errCode = this.db.RemoveNotificationByCorrelationKey(token.GetUserId(), request.CorrelationKey)
+		if err == nil {
+			go this.handleWsNotificationDelete(token.GetUserId(), []string{result.Id})
+			go this.handleFCMNotificationDelete(token.GetUserId(), []string{result.Id})
+		}
+		return result, err, errCode
+	}
+	settings, err, errCode := this.getSettings(token.GetUserId())
+	if err != nil {
+		return result, err, errCode
+	}
+	result, err, errCode = this.db.ResolveNotification(token.GetUserId(), request.CorrelationKey, time.Now().Truncate(time.Millisecond))
+	if err == nil {
+		this.handleUpdate(result, *token, settings)
+	}
+	return result, err, errCode
+}
+
 func (this *Controller) DeleteMultipleNotifications(token auth.Token, ids []string) (err error, errCode int) {
 	err, errCode = this.db.RemoveNotifications(token.GetUserId(), ids)
 	if err == nil {
@@ -129,6 +291,35 @@ func (this *Controller) DeleteMultipleNotifications(token auth.Token, ids []stri
 	*/
 }
 
+func (this *Controller) SetNotificationsReadState(token auth.Token, request model.NotificationReadStateRequest) (result model.NotificationReadState, err error, errCode int) {
+	if len(request.Ids) > 0 && request.Filter != nil {
+		return result, errors.New("expect either ids or filter"), http.StatusBadRequest
+	}
+	if len(request.Ids) == 0 && request.Filter == nil {
+		return result, errors.New("expect ids or filter"), http.StatusBadRequest
+	}
+	options := persistence.ListOptions{}
+	if request.Filter != nil {
+		for _, topic := range request.Filter.Topics {
+			if !slices.Contains(append(model.AllTopics(), model.TopicUnknown), topic) {
+				return result, fmt.Errorf("unknown topic %s", topic), http.StatusBadRequest
+			}
+		}
+		options.Topics = request.Filter.Topics
+		options.CreatedAfter = request.Filter.CreatedAfter
+		options.CreatedBefore = request.Filter.CreatedBefore
+	}
+	result.IsRead = request.IsRead
+	result.Ids, err, errCode = this.db.SetNotificationsReadState(token.GetUserId(), request.Ids, options, append(model.AllTopics(), model.TopicUnknown), request.IsRead)
+	if err == nil && len(result.Ids) > 0 {
+		go this.handleWsNotificationReadState(token.GetUserId(), result)
+		go this.handleFCMNotificationReadState(token.GetUserId(), result)
+		go this.handleMqttNotificationReadState(token.GetUserId(), result)
+	}
+	return
+	// like delete, a read state change does not consider the channel and topic settings
+}
+
 func (this *Controller) getSettings(userId string) (model.Settings, error, int) {
 	settings, err, errCode := this.db.ReadSettings(userId)
 	if err != nil && errCode != http.StatusNotFound {
@@ -145,9 +336,8 @@ func (this *Controller) handleCreate(notification model.Notification, token auth
 		notification.Topic = model.TopicUnknown
 	}
 
-	conf, ok := settings.ChannelTopicConfig[model.ChannelEmail]
-	if !ok || slices.Contains(conf, notification.Topic) {
-		go this.handleEmailNotificationUpdate(token, notification)
+	if settings.ChannelEnabled(model.ChannelEmail, notification) && !this.holdBackForQuietHours(token.GetUserId(), model.ChannelEmail, notification, settings) {
+		this.deliverEmail(token, notification, settings)
 	}
 
 	this.handleUpdate(notification, token, settings)
@@ -158,16 +348,32 @@ func (this *Controller) handleUpdate(notification model.Notification, token auth
 		notification.Topic = model.TopicUnknown
 	}
 
-	conf, ok := settings.ChannelTopicConfig[model.ChannelWebsocket]
-	if !ok || slices.Contains(conf, notification.Topic) {
+	if settings.ChannelEnabled(model.ChannelWebsocket, notification) {
 		go this.handleWsNotificationUpdate(token.GetUserId(), notification)
 	}
-	conf, ok = settings.ChannelTopicConfig[model.ChannelMqtt]
-	if !ok || slices.Contains(conf, notification.Topic) {
-		go this.handleMqttNotificationUpdate(token.GetUserId(), notification)
+	if settings.ChannelEnabled(model.ChannelMqtt, notification) {
+		this.enqueueDelivery(token, model.ChannelMqtt, notification)
+	}
+	if settings.ChannelEnabled(model.ChannelFcm, notification) && !this.holdBackForQuietHours(token.GetUserId(), model.ChannelFcm, notification, settings) {
+		this.enqueueDelivery(token, model.ChannelFcm, notification)
+	}
+}
+
+// holdBackForQuietHours reports whether the users quiet hours suppress the delivery on the channel.
+// if configured, the delivery is deferred until the quiet hours end.
+func (this *Controller) holdBackForQuietHours(userId string, channel model.Channel, notification model.Notification, settings model.Settings) bool {
+	if notification.IsRead {
+		return false // read state updates do not disturb
+	}
+	end, quiet, deferDelivery := settings.QuietUntil(channel, notification, time.Now())
+	if !quiet {
+		return false
 	}
-	conf, ok = settings.ChannelTopicConfig[model.ChannelFcm]
-	if !ok || slices.Contains(conf, notification.Topic) {
-		go this.handleFCMNotificationUpdate(token.GetUserId(), notification)
+	if deferDelivery {
+		err := this.db.DeferDelivery(model.NewDeferredDelivery(userId, notification, channel, end))
+		if err != nil {
+			log.Println("ERROR: unable to defer", channel, "delivery of notification", notification.Id, err)
+		}
 	}
+	return true
 }
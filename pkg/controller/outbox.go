@@ -0,0 +1,189 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/SENERGY-Platform/notifier/pkg/auth"
+	"github.com/SENERGY-Platform/notifier/pkg/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// a worker that does not finish a job within the lease is considered dead and the job is attempted again
+const outboxJobLease = 5 * time.Minute
+
+type outboxConfig struct {
+	pollInterval    time.Duration
+	retryBackoff    time.Duration
+	maxRetryBackoff time.Duration
+}
+
+func (this *Controller) startOutboxWorkers(ctx context.Context) (err error) {
+	if this.config.OutboxWorkers <= 0 {
+		return nil
+	}
+	this.outbox = &outboxConfig{}
+	this.outbox.pollInterval, err = time.ParseDuration(this.config.OutboxPollInterval)
+	if err != nil {
+		return err
+	}
+	this.outbox.retryBackoff, err = time.ParseDuration(this.config.OutboxRetryBackoff)
+	if err != nil {
+		return err
+	}
+	this.outbox.maxRetryBackoff, err = time.ParseDuration(this.config.OutboxMaxRetryBackoff)
+	if err != nil {
+		return err
+	}
+	for i := int64(0); i < this.config.OutboxWorkers; i++ {
+		go this.runOutboxWorker(ctx)
+	}
+	return nil
+}
+
+// enqueueDelivery persists the delivery of the notification on the channel as outbox job.
+// the first attempt is made right away with the given notification and counts as claimed by this instance;
+// retries and jobs interrupted by a restart are run by the outbox workers.
+// without outbox workers, the notification is delivered once without retries.
+func (this *Controller) enqueueDelivery(token auth.Token, channel model.Channel, notification model.Notification) {
+	if this.outbox == nil {
+		go this.deliverWithoutRetry(token, channel, notification)
+		return
+	}
+	now := time.Now()
+	job := model.OutboxJob{
+		Id:             primitive.NewObjectID().Hex(),
+		UserId:         token.GetUserId(),
+		NotificationId: notification.Id,
+		Channel:        channel,
+		State:          model.OutboxJobPending,
+		Attempts:       1,
+		CreatedAt:      now,
+		NextAttemptAt:  now.Add(outboxJobLease),
+	}
+	err := this.db.EnqueueOutboxJob(job)
+	if err != nil {
+		log.Println("ERROR: unable to enqueue", channel, "delivery of notification", notification.Id, "-> deliver without retry:", err)
+		go this.deliverWithoutRetry(token, channel, notification)
+		return
+	}
+	go func() {
+		delivered, err := this.deliverToChannel(token, channel, notification, 1, nil)
+		this.finishOutboxAttempt(job, delivered, err)
+	}()
+}
+
+func (this *Controller) deliverWithoutRetry(token auth.Token, channel model.Channel, notification model.Notification) {
+	_, err := this.deliverToChannel(token, channel, notification, 1, nil)
+	if err != nil {
+		log.Println("ERROR: unable to deliver notification", notification.Id, "on", channel, err)
+	}
+}
+
+// deliverToChannel returns the targets that received the notification, if the channel has multiple targets.
+// attempt counts the deliveries of the notification on the channel, skipTargets have been delivered by previous attempts.
+func (this *Controller) deliverToChannel(token auth.Token, channel model.Channel, notification model.Notification, attempt int, skipTargets []string) (delivered []string, err error) {
+	switch channel {
+	case model.ChannelEmail:
+		if token.Email == "" {
+			internalToken, err := this.createInternalUserToken(token.GetUserId())
+			if err != nil {
+				return nil, err
+			}
+			token = *internalToken
+		}
+		return nil, this.handleEmailNotificationUpdate(token, notification)
+	case model.ChannelMqtt:
+		return this.handleMqttNotificationUpdate(token.GetUserId(), notification, attempt, skipTargets)
+	case model.ChannelFcm:
+		return nil, this.handleFCMNotificationUpdate(token.GetUserId(), notification)
+	default:
+		return nil, fmt.Errorf("unsupported outbox channel %s", channel)
+	}
+}
+
+func (this *Controller) runOutboxWorker(ctx context.Context) {
+	for {
+		job, found, err := this.db.ClaimOutboxJob(time.Now(), outboxJobLease)
+		if err != nil {
+			log.Println("ERROR: unable to claim outbox job", err)
+		}
+		if found {
+			delivered, err := this.executeOutboxJob(job)
+			this.finishOutboxAttempt(job, delivered, err)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(this.outbox.pollInterval):
+		}
+	}
+}
+
+// finishOutboxAttempt marks the job as done, schedules a retry or moves it to the dead letter state
+func (this *Controller) finishOutboxAttempt(job model.OutboxJob, delivered []string, err error) {
+	now := time.Now()
+	job.DeliveredTargets = append(job.DeliveredTargets, delivered...)
+	switch {
+	case err == nil:
+		job.State = model.OutboxJobDone
+		job.LastError = ""
+		job.FinishedAt = &now
+	case int64(job.Attempts) >= this.config.OutboxMaxAttempts:
+		log.Println("ERROR: giving up", job.Channel, "delivery of notification", job.NotificationId, "after", job.Attempts, "attempts:", err)
+		job.State = model.OutboxJobDeadLetter
+		job.LastError = err.Error()
+		job.FinishedAt = &now
+	default:
+		if this.config.Debug {
+			log.Println("DEBUG: retry", job.Channel, "delivery of notification", job.NotificationId, "after attempt", job.Attempts, err)
+		}
+		job.LastError = err.Error()
+		job.NextAttemptAt = now.Add(outboxRetryDelay(job.Attempts, this.outbox.retryBackoff, this.outbox.maxRetryBackoff))
+	}
+	err = this.db.SetOutboxJob(job)
+	if err != nil {
+		log.Println("ERROR: unable to update outbox job", job.Id, err)
+	}
+}
+
+// executeOutboxJob delivers the current state of the notification; deleted notifications need no delivery
+func (this *Controller) executeOutboxJob(job model.OutboxJob) (delivered []string, err error) {
+	notification, err, errCode := this.db.ReadNotification(job.UserId, job.NotificationId)
+	if errCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return this.deliverToChannel(auth.Token{Sub: job.UserId}, job.Channel, notification, job.Attempts, job.DeliveredTargets)
+}
+
+// outboxRetryDelay doubles the backoff with each failed attempt, limited to maxBackoff
+func outboxRetryDelay(attempts int, backoff time.Duration, maxBackoff time.Duration) time.Duration {
+	delay := backoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff)
+}
@@ -34,6 +34,10 @@ func (this *Controller) GetPlatformBroker(token auth.Token) (platformBroker mode
 }
 
 func (this *Controller) SetPlatformBroker(token auth.Token, platformBroker model.PlatformBroker) (result model.PlatformBroker, err error, errCode int) {
+	err = platformBroker.MqttPayloadOptions.Validate()
+	if err != nil {
+		return result, err, http.StatusBadRequest
+	}
 	platformBroker.UserId = token.GetUserId()
 	err, errCode = this.db.SetPlatformBroker(platformBroker)
 	return platformBroker, err, errCode
@@ -0,0 +1,150 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/SENERGY-Platform/notifier/pkg/auth"
+	"github.com/SENERGY-Platform/notifier/pkg/model"
+)
+
+const scheduledDeliveryBatchSize = 100
+
+// scheduled notifications that are not delivered within the lease, e.g. because the instance stopped, are claimed again
+const scheduledDeliveryClaimLease = 5 * time.Minute
+
+// startScheduledDelivery polls the database for notifications with a due deliver_at, deliveries deferred by quiet hours and due email digests.
+// polling instead of in-memory timers keeps scheduled notifications across restarts.
+// the polling can not be disabled, scheduled and deferred deliveries would pile up without being delivered.
+func (this *Controller) startScheduledDelivery(ctx context.Context) error {
+	if this.config.ScheduledDeliveryInterval == "" || this.config.ScheduledDeliveryInterval == "-" {
+		return errors.New("scheduled_delivery_interval is required to deliver scheduled notifications, deliveries deferred by quiet hours and email digests")
+	}
+	interval, err := time.ParseDuration(this.config.ScheduledDeliveryInterval)
+	if err != nil {
+		return err
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				this.deliverDueNotifications()
+				this.deliverDeferredDeliveries()
+				this.sendDueEmailDigests()
+			}
+		}
+	}()
+	return nil
+}
+
+func (this *Controller) deliverDueNotifications() {
+	for {
+		due, err := this.db.ClaimDueNotifications(time.Now(), scheduledDeliveryClaimLease, scheduledDeliveryBatchSize)
+		if err != nil {
+			log.Println("ERROR: unable to claim scheduled notifications", err)
+			return
+		}
+		for _, notification := range due {
+			this.deliverScheduledNotification(notification)
+		}
+		if len(due) < scheduledDeliveryBatchSize {
+			return
+		}
+	}
+}
+
+// deliverScheduledNotification hands the notification to the delivery channels and completes it afterward,
+// so that a notification is delivered again rather than lost if the instance stops in between.
+func (this *Controller) deliverScheduledNotification(notification model.Notification) {
+	token, err := this.createInternalUserToken(notification.UserId)
+	if err != nil {
+		log.Println("ERROR: unable to deliver scheduled notification", notification.Id, err)
+		return
+	}
+	settings, err, _ := this.getSettings(notification.UserId)
+	if err != nil {
+		log.Println("ERROR: unable to deliver scheduled notification", notification.Id, err)
+		return
+	}
+	if this.config.Debug {
+		log.Println("DEBUG: deliver scheduled notification", notification.Id)
+	}
+	notification.CreatedAt = *notification.DeliverAt
+	notification.DeliverAt = nil
+	this.handleCreate(notification, *token, settings)
+	err = this.db.CompleteScheduledNotification(notification.Id)
+	if err != nil {
+		log.Println("ERROR: unable to complete scheduled notification", notification.Id, err)
+	}
+}
+
+func (this *Controller) deliverDeferredDeliveries() {
+	for {
+		due, err := this.db.ClaimDueDeferredDeliveries(time.Now(), scheduledDeliveryBatchSize)
+		if err != nil {
+			log.Println("ERROR: unable to claim deferred deliveries", err)
+			return
+		}
+		for _, delivery := range due {
+			this.deliverDeferred(delivery)
+		}
+		if len(due) < scheduledDeliveryBatchSize {
+			return
+		}
+	}
+}
+
+func (this *Controller) deliverDeferred(delivery model.DeferredDelivery) {
+	notification, err, errCode := this.db.ReadNotification(delivery.UserId, delivery.NotificationId)
+	if errCode == http.StatusNotFound {
+		return // deleted during quiet hours
+	}
+	if err != nil {
+		log.Println("ERROR: unable to deliver deferred notification", delivery.NotificationId, err)
+		return
+	}
+	if notification.IsRead {
+		return // already seen on another channel
+	}
+	settings, err, _ := this.getSettings(delivery.UserId)
+	if err != nil {
+		log.Println("ERROR: unable to deliver deferred notification", delivery.NotificationId, err)
+		return
+	}
+	if !settings.ChannelEnabled(delivery.Channel, notification) || this.holdBackForQuietHours(delivery.UserId, delivery.Channel, notification, settings) {
+		return // settings changed in the meantime
+	}
+	if this.config.Debug {
+		log.Println("DEBUG: deliver deferred", delivery.Channel, "notification", notification.Id)
+	}
+	token := auth.Token{Sub: delivery.UserId}
+	switch delivery.Channel {
+	case model.ChannelFcm:
+		this.enqueueDelivery(token, model.ChannelFcm, notification)
+	case model.ChannelEmail:
+		this.deliverEmail(token, notification, settings)
+	}
+}
@@ -17,15 +17,21 @@
 package controller
 
 import (
+	"fmt"
 	"net/http"
+	"slices"
 
 	"github.com/SENERGY-Platform/notifier/pkg/auth"
 	"github.com/SENERGY-Platform/notifier/pkg/model"
 )
 
 func (this *Controller) GetSettings(token auth.Token) (settings model.Settings, err error, errCode int) {
+	return this.getSettingsWithDefaults(token.GetUserId())
+}
+
+func (this *Controller) getSettingsWithDefaults(userId string) (settings model.Settings, err error, errCode int) {
 	baseSettings := model.DefaultSettings()
-	settings, err, errCode = this.db.ReadSettings(token.GetUserId())
+	settings, err, errCode = this.db.ReadSettings(userId)
 	if err != nil && errCode == http.StatusNotFound {
 		return baseSettings, nil, http.StatusOK
 	}
@@ -39,11 +45,44 @@ func (this *Controller) GetSettings(token auth.Token) (settings model.Settings,
 			baseSettings.ChannelTopicConfig[channel] = set
 		}
 	}
-	settings = baseSettings
+	settings.ChannelTopicConfig = baseSettings.ChannelTopicConfig
 	return
 }
 
 func (this *Controller) SetSettings(token auth.Token, settings model.Settings) (result model.Settings, err error, errCode int) {
+	for topic, days := range settings.RetentionDays {
+		if !slices.Contains(append(model.AllTopics(), model.TopicUnknown), topic) {
+			return result, fmt.Errorf("unknown topic %s", topic), http.StatusBadRequest
+		}
+		if days <= 0 {
+			return result, fmt.Errorf("retention days for topic %s must be positive", topic), http.StatusBadRequest
+		}
+	}
+	for channel, severity := range settings.ChannelMinSeverity {
+		if !slices.Contains(model.AllChannels(), channel) {
+			return result, fmt.Errorf("unknown channel %s", channel), http.StatusBadRequest
+		}
+		if !slices.Contains(model.AllSeverities(), severity) {
+			return result, fmt.Errorf("unknown severity %s", severity), http.StatusBadRequest
+		}
+	}
+	for channel, quietHours := range settings.QuietHours {
+		if !slices.Contains(model.QuietHoursChannels(), channel) {
+			return result, fmt.Errorf("quiet hours are not supported for channel %s", channel), http.StatusBadRequest
+		}
+		err = quietHours.Validate()
+		if err != nil {
+			return result, err, http.StatusBadRequest
+		}
+		for _, topic := range quietHours.OverrideTopics {
+			if !slices.Contains(append(model.AllTopics(), model.TopicUnknown), topic) {
+				return result, fmt.Errorf("unknown topic %s", topic), http.StatusBadRequest
+			}
+		}
+	}
+	if settings.EmailDelivery != "" && !slices.Contains(model.AllEmailDeliveries(), settings.EmailDelivery) {
+		return result, fmt.Errorf("unknown email delivery %s", settings.EmailDelivery), http.StatusBadRequest
+	}
 	settings.UserId = token.GetUserId()
 	err, errCode = this.db.SetSettings(settings)
 	return settings, err, errCode
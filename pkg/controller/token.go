@@ -19,6 +19,7 @@ package controller
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
@@ -28,6 +29,9 @@ import (
 	"github.com/SENERGY-Platform/notifier/pkg/auth"
 )
 
+// ErrUserNotFound is returned by createInternalUserToken if keycloak does not know the user
+var ErrUserNotFound = errors.New("user not found")
+
 type KeycloakUser struct {
 	ID               string `json:"id"`
 	CreatedTimestamp int64  `json:"createdTimestamp"`
@@ -107,6 +111,9 @@ func (c *Controller) createInternalUserToken(userid string) (token *auth.Token,
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, userid)
+	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
 		log.Println("ERROR: getOpenidToken()", resp.StatusCode, string(body))
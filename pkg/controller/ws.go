@@ -149,6 +149,7 @@ func (this *Controller) handleWsNotificationUpdate(userId string, notification m
 	if !ok {
 		return
 	}
+	counts, countErr := this.getWsCounts(userId)
 	for i := range sessions {
 		i := i // thread safety
 		go func() {
@@ -163,6 +164,7 @@ func (this *Controller) handleWsNotificationUpdate(userId string, notification m
 			if err != nil {
 				log.Println("ERROR: unable to notify session", sessions[i].id)
 			}
+			this.wsSendCounts(sessions[i], counts, countErr)
 		}()
 	}
 }
@@ -172,6 +174,7 @@ func (this *Controller) handleWsNotificationDelete(userId string, ids []string)
 	if !ok {
 		return
 	}
+	counts, countErr := this.getWsCounts(userId)
 	for i := range sessions {
 		i := i // thread safety
 		go func() {
@@ -188,10 +191,56 @@ func (this *Controller) handleWsNotificationDelete(userId string, ids []string)
 					log.Println("ERROR: unable to notify session", sessions[i].id)
 				}
 			}
+			this.wsSendCounts(sessions[i], counts, countErr)
 		}()
 	}
 }
 
+func (this *Controller) handleWsNotificationReadState(userId string, state model.NotificationReadState) {
+	sessions, ok := this.sessions[userId]
+	if !ok {
+		return
+	}
+	counts, countErr := this.getWsCounts(userId)
+	for i := range sessions {
+		i := i // thread safety
+		go func() {
+			if sessions[i].token == nil || sessions[i].token.IsExpired() {
+				err := this.wsSendAuthRequest(sessions[i])
+				if err != nil {
+					log.Println("ERROR: unable to send auth request", err)
+				}
+				return
+			}
+			err := this.wsSend(sessions[i], model.WsUpdateReadStateManyType, state)
+			if err != nil {
+				log.Println("ERROR: unable to notify session", sessions[i].id)
+			}
+			this.wsSendCounts(sessions[i], counts, countErr)
+		}()
+	}
+}
+
+func (this *Controller) getWsCounts(userId string) (counts model.NotificationCounts, err error) {
+	topics, err, _ := this.getChannelTopics(userId, model.ChannelWebsocket)
+	if err != nil {
+		return counts, err
+	}
+	counts, err, _ = this.db.CountNotifications(userId, topics)
+	return counts, err
+}
+
+func (this *Controller) wsSendCounts(session *WsSession, counts model.NotificationCounts, countErr error) {
+	if countErr != nil {
+		log.Println("ERROR: unable to count notifications", countErr)
+		return
+	}
+	err := this.wsSend(session, model.WsCountType, counts)
+	if err != nil {
+		log.Println("ERROR: unable to notify session", session.id)
+	}
+}
+
 func (this *Controller) handleWsRefresh(session *WsSession) error {
 	if session.token == nil || session.token.IsExpired() {
 		return this.wsSendAuthRequest(session)
@@ -0,0 +1,179 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package kafka
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// PermanentError marks handler errors that retrying does not fix
+type PermanentError struct {
+	Err error
+}
+
+func (this PermanentError) Error() string {
+	return this.Err.Error()
+}
+
+func (this PermanentError) Unwrap() error {
+	return this.Err
+}
+
+// Permanent wraps the error, so that the consumer does not retry the message
+func Permanent(err error) error {
+	return PermanentError{Err: err}
+}
+
+// NewConsumer reads the topic as member of the consumer group and commits each message after the handler succeeded.
+// Failed messages are retried after retryInterval, which holds back the following messages of the partition.
+// Messages failing with a PermanentError or more than maxRetries times (negative retries forever) are written
+// to the deadLetterTopic and committed. An empty deadLetterTopic or "-" drops these messages instead.
+// The topics are created if missing.
+func NewConsumer(ctx context.Context, url string, groupId string, topic string, retryInterval time.Duration, maxRetries int64, deadLetterTopic string, handler func(message []byte) error) error {
+	err := InitTopic(url, topic)
+	if err != nil {
+		return err
+	}
+	var deadLetterWriter *kafka.Writer
+	if deadLetterTopic != "" && deadLetterTopic != "-" {
+		err = InitTopic(url, deadLetterTopic)
+		if err != nil {
+			return err
+		}
+		deadLetterWriter = &kafka.Writer{
+			Addr:     kafka.TCP(url),
+			Topic:    deadLetterTopic,
+			Balancer: &kafka.LeastBytes{},
+		}
+	}
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     []string{url},
+		GroupID:     groupId,
+		Topic:       topic,
+		MinBytes:    1,
+		MaxBytes:    10e6,
+		MaxWait:     time.Second,
+		StartOffset: kafka.FirstOffset,
+		ErrorLogger: kafka.LoggerFunc(func(format string, args ...interface{}) {
+			log.Printf("ERROR: kafka consumer "+format, args...)
+		}),
+	})
+	go func() {
+		defer reader.Close()
+		if deadLetterWriter != nil {
+			defer deadLetterWriter.Close()
+		}
+		for {
+			msg, err := reader.FetchMessage(ctx)
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				log.Println("ERROR: unable to fetch kafka message", topic, err)
+				time.Sleep(retryInterval)
+				continue
+			}
+			for retries := int64(0); ; retries++ {
+				err = handler(msg.Value)
+				if err == nil {
+					break
+				}
+				if errors.As(err, &PermanentError{}) || (maxRetries >= 0 && retries >= maxRetries) {
+					err = deadLetter(ctx, deadLetterWriter, msg, err, retryInterval)
+					break
+				}
+				log.Println("ERROR: unable to handle kafka message, retry in", retryInterval.String(), topic, msg.Partition, msg.Offset, err)
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(retryInterval):
+				}
+			}
+			if err != nil {
+				return // context canceled while writing the dead letter, the message is not committed
+			}
+			err = reader.CommitMessages(ctx, msg)
+			if err != nil && ctx.Err() == nil {
+				log.Println("ERROR: unable to commit kafka message", topic, msg.Partition, msg.Offset, err)
+			}
+		}
+	}()
+	return nil
+}
+
+// deadLetter writes the failed message with its origin and error as headers to the dead letter topic.
+// writing is retried until it succeeds or the context is canceled, so that no message is lost.
+func deadLetter(ctx context.Context, writer *kafka.Writer, msg kafka.Message, handlerErr error, retryInterval time.Duration) error {
+	if writer == nil {
+		log.Println("WARNING: dropping kafka message", msg.Topic, msg.Partition, msg.Offset, handlerErr, string(msg.Value))
+		return nil
+	}
+	log.Println("WARNING: moving kafka message to dead letter topic", writer.Topic, msg.Topic, msg.Partition, msg.Offset, handlerErr)
+	headers := append([]kafka.Header{}, msg.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: "error", Value: []byte(handlerErr.Error())},
+		kafka.Header{Key: "original_topic", Value: []byte(msg.Topic)},
+		kafka.Header{Key: "original_partition", Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: "original_offset", Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+	)
+	for {
+		err := writer.WriteMessages(ctx, kafka.Message{Key: msg.Key, Value: msg.Value, Headers: headers})
+		if err == nil {
+			return nil
+		}
+		log.Println("ERROR: unable to write kafka dead letter, retry in", retryInterval.String(), writer.Topic, err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// InitTopic creates the topic with one partition if it does not exist
+func InitTopic(url string, topic string) error {
+	conn, err := kafka.Dial("tcp", url)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	controller, err := conn.Controller()
+	if err != nil {
+		return err
+	}
+	controllerConn, err := kafka.Dial("tcp", net.JoinHostPort(controller.Host, strconv.Itoa(controller.Port)))
+	if err != nil {
+		return err
+	}
+	defer controllerConn.Close()
+	err = controllerConn.CreateTopics(kafka.TopicConfig{
+		Topic:             topic,
+		NumPartitions:     1,
+		ReplicationFactor: 1,
+	})
+	if errors.Is(err, kafka.TopicAlreadyExists) {
+		return nil
+	}
+	return err
+}
@@ -16,19 +16,50 @@
 
 package model
 
-import "time"
+import (
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
 
 type Broker struct {
-	Id        string    `json:"id"`
-	Address   string    `json:"address"`
-	User      string    `json:"user"`
-	Password  string    `json:"password"`
-	Topic     string    `json:"topic"`
-	Qos       uint8     `json:"qos"`
-	Enabled   bool      `json:"enabled"`
-	CreatedAt time.Time `json:"created_at" bson:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
-	UserId    string    `json:"-" bson:"user_id"`
+	Id         string       `json:"id"`
+	Address    string       `json:"address"`
+	User       string       `json:"user"`
+	Password   string       `json:"password"`
+	CaCert     string       `json:"ca_cert,omitempty"`     // PEM, trusted in addition to the system roots
+	ClientCert string       `json:"client_cert,omitempty"` // PEM, enables mutual tls together with ClientKey
+	ClientKey  string       `json:"client_key,omitempty"`
+	Insecure   bool         `json:"insecure,omitempty"` // skips verification of the broker certificate
+	Topic      string       `json:"topic"`              // text/template rendered with the notification, e.g. notifications/{{.Topic}}/{{.Severity}}
+	Topics     []Topic      `json:"topics,omitempty"`   // notification topics published to this broker, empty for all
+	Qos        uint8        `json:"qos"`
+	Enabled    bool         `json:"enabled"`
+	CreatedAt  time.Time    `json:"created_at" bson:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at" bson:"updated_at"`
+	UserId     string       `json:"-" bson:"user_id"`
+	Health     BrokerHealth `json:"health" bson:"health"` // maintained by the notifier, ignored on create and update
+
+	MqttPayloadOptions `bson:",inline"`
+
+	ProtocolVersion      uint8  `json:"protocol_version,omitempty" bson:"protocol_version,omitempty"`             // MqttProtocolVersion5 to opt in, otherwise MQTT 3.1.1 is used
+	MessageExpirySeconds uint32 `json:"message_expiry_seconds,omitempty" bson:"message_expiry_seconds,omitempty"` // MQTT v5 message expiry interval, 0 for none
+}
+
+// maxTopicLength is the maximum length of MQTT topics in bytes
+const maxTopicLength = 65535
+
+const MqttProtocolVersion311 = 4
+const MqttProtocolVersion5 = 5
+
+// BrokerHealth tracks the deliveries to a broker. Brokers are disabled after too many consecutive failures.
+type BrokerHealth struct {
+	LastSuccessAt       *time.Time `json:"last_success_at,omitempty" bson:"last_success_at,omitempty"`
+	LastErrorAt         *time.Time `json:"last_error_at,omitempty" bson:"last_error_at,omitempty"`
+	LastError           string     `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	ConsecutiveFailures int64      `json:"consecutive_failures" bson:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at,omitempty" bson:"disabled_at,omitempty"` // set if the broker was disabled automatically
 }
 
 func (b *Broker) Equal(other interface{}) bool {
@@ -40,17 +71,68 @@ func (b *Broker) Equal(other interface{}) bool {
 		b.Address == otherB.Address &&
 		b.User == otherB.User &&
 		b.Password == otherB.Password &&
+		b.CaCert == otherB.CaCert &&
+		b.ClientCert == otherB.ClientCert &&
+		b.ClientKey == otherB.ClientKey &&
+		b.Insecure == otherB.Insecure &&
 		b.Topic == otherB.Topic &&
+		b.MqttPayloadOptions == otherB.MqttPayloadOptions &&
+		b.ProtocolVersion == otherB.ProtocolVersion &&
+		b.MessageExpirySeconds == otherB.MessageExpirySeconds &&
+		slices.Equal(b.Topics, otherB.Topics) &&
 		b.CreatedAt.Equal(otherB.CreatedAt) &&
 		b.UpdatedAt.Equal(otherB.UpdatedAt) &&
 		b.UserId == otherB.UserId
 }
 
+// AcceptsTopic reports if notifications of the topic are published to the broker
+func (b *Broker) AcceptsTopic(topic Topic) bool {
+	return len(b.Topics) == 0 || slices.Contains(b.Topics, topic)
+}
+
+// TopicIsTemplate reports if the topic depends on the notification.
+// Events that do not belong to a notification are not published to brokers with topic templates.
+func (b *Broker) TopicIsTemplate() bool {
+	return strings.Contains(b.Topic, "{{")
+}
+
+// RenderTopic renders the topic template with the notification
+func (b *Broker) RenderTopic(notification Notification) (string, error) {
+	if !b.TopicIsTemplate() {
+		return b.Topic, nil
+	}
+	tmpl, err := parseUserTemplate(b.Topic)
+	if err != nil {
+		return "", err
+	}
+	topic, err := renderUserTemplate(tmpl, notification, maxTopicLength)
+	if err != nil {
+		return "", err
+	}
+	if topic == "" {
+		return "", errors.New("rendered topic is empty")
+	}
+	if strings.ContainsAny(topic, "+#") {
+		return "", errors.New("rendered topic " + topic + " contains wildcards")
+	}
+	return topic, nil
+}
+
+// BrokerTestResult reports the outcome of a broker test. Error contains the exact connect or publish error.
+type BrokerTestResult struct {
+	Connected        bool   `json:"connected"`
+	Published        bool   `json:"published"`
+	ConnectLatencyMs int64  `json:"connect_latency_ms"`
+	PublishLatencyMs int64  `json:"publish_latency_ms,omitempty"`
+	Error            string `json:"error,omitempty"`
+}
+
 type BrokerList struct {
-	Total   int64    `json:"total"`
-	Limit   int      `json:"limit"`
-	Offset  int      `json:"offset"`
-	Brokers []Broker `json:"brokers"`
+	Total      int64    `json:"total"` // -1 if not counted
+	Limit      int      `json:"limit"`
+	Offset     int      `json:"offset"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+	Brokers    []Broker `json:"brokers"`
 }
 
 func (l *BrokerList) Equal(other interface{}) bool {
@@ -58,7 +140,7 @@ func (l *BrokerList) Equal(other interface{}) bool {
 	if !ok {
 		return false
 	}
-	if l.Offset != otherL.Offset || l.Limit != otherL.Limit || l.Total != otherL.Total {
+	if l.Offset != otherL.Offset || l.Limit != otherL.Limit || l.Total != otherL.Total || l.NextCursor != otherL.NextCursor {
 		return false
 	}
 	for i := range l.Brokers {
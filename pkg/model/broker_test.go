@@ -0,0 +1,64 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import "testing"
+
+func TestBrokerRenderTopic(t *testing.T) {
+	notification := Notification{Id: "n1", Topic: TopicDeveloper, Severity: SeverityWarning}
+	cases := []struct {
+		name     string
+		topic    string
+		expected string
+		err      bool
+	}{
+		{"static", "notifications", "notifications", false},
+		{"template", "notifications/{{.Topic}}/{{.Severity}}", "notifications/developer/warning", false},
+		{"id", "n/{{.Id}}", "n/n1", false},
+		{"unknown field", "n/{{.Foo}}", "", true},
+		{"invalid", "n/{{.Topic", "", true},
+		{"empty", "{{.Link}}", "", true},
+		{"wildcard", "n/{{.Topic}}/#", "", true},
+		{"condition", "n/{{if eq .Severity \"warning\"}}warn{{else}}other{{end}}", "n/warn", false},
+		{"range", "n/{{range 100000000000}}x{{end}}", "", true},
+		{"with", "n/{{with .Topic}}{{.}}{{end}}", "", true},
+		{"define", "{{define \"t\"}}x{{end}}n", "", true},
+		{"printf", "n/{{printf \"%0999999999d\" 1}}", "", true},
+	}
+	for _, c := range cases {
+		broker := Broker{Topic: c.topic}
+		actual, err := broker.RenderTopic(notification)
+		if (err != nil) != c.err {
+			t.Error(c.name, err)
+			continue
+		}
+		if actual != c.expected {
+			t.Error(c.name, actual, c.expected)
+		}
+	}
+}
+
+func TestBrokerAcceptsTopic(t *testing.T) {
+	all := Broker{}
+	if !all.AcceptsTopic(TopicDeveloper) {
+		t.Error("broker without topics should accept all")
+	}
+	filtered := Broker{Topics: []Topic{TopicIncident}}
+	if !filtered.AcceptsTopic(TopicIncident) || filtered.AcceptsTopic(TopicDeveloper) {
+		t.Error("unexpected topic filter")
+	}
+}
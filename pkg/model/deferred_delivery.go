@@ -0,0 +1,38 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import "time"
+
+// DeferredDelivery is a channel delivery postponed until the end of the users quiet hours
+type DeferredDelivery struct {
+	Id             string    `bson:"_id"` // one deferred delivery per notification and channel
+	UserId         string    `bson:"user_id"`
+	NotificationId string    `bson:"notification_id"`
+	Channel        Channel   `bson:"channel"`
+	DeliverAt      time.Time `bson:"deliver_at"`
+}
+
+func NewDeferredDelivery(userId string, notification Notification, channel Channel, deliverAt time.Time) DeferredDelivery {
+	return DeferredDelivery{
+		Id:             notification.Id + "_" + channel,
+		UserId:         userId,
+		NotificationId: notification.Id,
+		Channel:        channel,
+		DeliverAt:      deliverAt,
+	}
+}
@@ -0,0 +1,39 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import "time"
+
+const DeliveryTargetPlatformBroker = "platform_broker"
+
+// DeliveryAttempt records the result of delivering a notification to one target of a channel.
+// targets are the email address, the broker id, DeliveryTargetPlatformBroker or the suffix of a fcm token.
+type DeliveryAttempt struct {
+	Id             string    `json:"id" bson:"_id"`
+	UserId         string    `json:"-" bson:"user_id"`
+	NotificationId string    `json:"notification_id" bson:"notification_id"`
+	Channel        Channel   `json:"channel" bson:"channel"`
+	Target         string    `json:"target" bson:"target"`
+	Success        bool      `json:"success" bson:"success"`
+	Error          string    `json:"error,omitempty" bson:"error,omitempty"`
+	Timestamp      time.Time `json:"timestamp" bson:"timestamp"`
+}
+
+type NotificationDeliveries struct {
+	Attempts []DeliveryAttempt `json:"attempts"` // oldest first
+	Jobs     []OutboxJob       `json:"jobs"`     // pending and finished outbox jobs of the notification
+}
@@ -0,0 +1,41 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import "time"
+
+// EmailDigestEntry is a notification waiting for the next email digest of its user.
+// while a digest is sent, its entries stay pending with the claim time; stale claims are claimed again.
+// after sending, the entry is kept with the digest id as record of what was included.
+type EmailDigestEntry struct {
+	Id             string     `bson:"_id"`
+	UserId         string     `bson:"user_id"`
+	NotificationId string     `bson:"notification_id"`
+	DueAt          time.Time  `bson:"due_at"`
+	Pending        bool       `bson:"pending,omitempty"`
+	DigestId       string     `bson:"digest_id,omitempty"`
+	ClaimedAt      *time.Time `bson:"claimed_at,omitempty"`
+	Attempts       int        `bson:"attempts,omitempty"` // failed sending attempts
+	SentAt         *time.Time `bson:"sent_at,omitempty"`
+}
+
+type EmailDigest struct {
+	Id              string
+	UserId          string
+	NotificationIds []string
+	Attempts        int // highest failed attempts of the entries
+}
@@ -0,0 +1,33 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+// NotificationIngestion is the payload of notifications created over mqtt.
+// The options match the query parameters of POST /notifications.
+type NotificationIngestion struct {
+	Notification
+	IgnoreDuplicatesWithinSeconds *int64 `json:"ignore_duplicates_within_seconds,omitempty"`
+	PushDuplicates                bool   `json:"push_duplicates,omitempty"`
+}
+
+// IngestionError is published to the ingestion error topic for messages that could not be handled
+type IngestionError struct {
+	Topic   string `json:"topic"`
+	Payload string `json:"payload"`
+	Error   string `json:"error"`
+	Code    int    `json:"code"`
+}
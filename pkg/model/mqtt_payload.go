@@ -0,0 +1,133 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+type MqttPayloadFormat string
+
+const MqttPayloadFormatFull MqttPayloadFormat = "full"         // the notification as returned by the api
+const MqttPayloadFormatCompact MqttPayloadFormat = "compact"   // CompactNotification
+const MqttPayloadFormatPlain MqttPayloadFormat = "plain"       // title and message as text
+const MqttPayloadFormatTemplate MqttPayloadFormat = "template" // text/template rendered with the notification, see parseUserTemplate
+
+// maxTemplatePayloadSize limits the messages rendered from payload templates
+const maxTemplatePayloadSize = 256 * 1024
+
+func AllMqttPayloadFormats() []MqttPayloadFormat {
+	return []MqttPayloadFormat{
+		MqttPayloadFormatFull,
+		MqttPayloadFormatCompact,
+		MqttPayloadFormatPlain,
+		MqttPayloadFormatTemplate,
+	}
+}
+
+// MqttPayloadOptions configures the messages published to a broker.
+// Events without notification, like read state changes, are only published in the full and compact format.
+type MqttPayloadOptions struct {
+	PayloadFormat   MqttPayloadFormat `json:"payload_format,omitempty" bson:"payload_format,omitempty"` // empty for full
+	PayloadTemplate string            `json:"payload_template,omitempty" bson:"payload_template,omitempty"`
+	Retain          bool              `json:"retain,omitempty" bson:"retain,omitempty"`
+}
+
+type CompactNotification struct {
+	Id        string    `json:"id"`
+	Title     string    `json:"title"`
+	Message   string    `json:"message,omitempty"`
+	Topic     Topic     `json:"topic"`
+	Severity  Severity  `json:"severity"`
+	Link      string    `json:"link,omitempty"`
+	IsRead    bool      `json:"isRead"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (this MqttPayloadOptions) Validate() error {
+	if this.PayloadFormat != "" && !slices.Contains(AllMqttPayloadFormats(), this.PayloadFormat) {
+		return fmt.Errorf("unknown payload format %s", this.PayloadFormat)
+	}
+	if this.PayloadFormat == MqttPayloadFormatTemplate {
+		if this.PayloadTemplate == "" {
+			return errors.New("missing payload template")
+		}
+		_, err := parseUserTemplate(this.PayloadTemplate)
+		if err != nil {
+			return fmt.Errorf("invalid payload template: %w", err)
+		}
+	}
+	return nil
+}
+
+// ContentType returns the MQTT v5 content type of rendered notifications
+func (this MqttPayloadOptions) ContentType() string {
+	if this.PayloadFormat == MqttPayloadFormatPlain || this.PayloadFormat == MqttPayloadFormatTemplate {
+		return "text/plain"
+	}
+	return "application/json"
+}
+
+// Render returns the message for the payload, which is either the notification or an event without notification.
+// ok is false if the format does not publish events.
+func (this MqttPayloadOptions) Render(notification *Notification, payload interface{}) (message string, ok bool, err error) {
+	switch this.PayloadFormat {
+	case MqttPayloadFormatCompact:
+		if notification != nil {
+			payload = CompactNotification{
+				Id:        notification.Id,
+				Title:     notification.Title,
+				Message:   notification.Message,
+				Topic:     notification.Topic,
+				Severity:  notification.Severity,
+				Link:      notification.Link,
+				IsRead:    notification.IsRead,
+				CreatedAt: notification.CreatedAt,
+			}
+		}
+	case MqttPayloadFormatPlain:
+		if notification == nil {
+			return "", false, nil
+		}
+		if notification.Message == "" {
+			return notification.Title, true, nil
+		}
+		return notification.Title + "\n" + notification.Message, true, nil
+	case MqttPayloadFormatTemplate:
+		if notification == nil {
+			return "", false, nil
+		}
+		tmpl, err := parseUserTemplate(this.PayloadTemplate)
+		if err != nil {
+			return "", false, err
+		}
+		message, err = renderUserTemplate(tmpl, notification, maxTemplatePayloadSize)
+		if err != nil {
+			return "", false, err
+		}
+		return message, true, nil
+	}
+	bytes, err := json.Marshal(payload)
+	if err != nil {
+		return "", false, err
+	}
+	return string(bytes), true, nil
+}
@@ -0,0 +1,103 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMqttPayloadOptionsRender(t *testing.T) {
+	notification := Notification{
+		Id:        "n1",
+		UserId:    "user1",
+		Title:     "title",
+		Message:   "message",
+		Topic:     TopicIncident,
+		Severity:  SeverityError,
+		CreatedAt: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	event := EventMessage{Type: WsUpdateReadStateManyType, Payload: NotificationReadState{IsRead: true}}
+	full, _ := json.Marshal(notification)
+	fullEvent, _ := json.Marshal(event)
+
+	cases := []struct {
+		name         string
+		options      MqttPayloadOptions
+		notification *Notification
+		payload      interface{}
+		expected     string
+		ok           bool
+	}{
+		{"default", MqttPayloadOptions{}, &notification, notification, string(full), true},
+		{"full", MqttPayloadOptions{PayloadFormat: MqttPayloadFormatFull}, &notification, notification, string(full), true},
+		{"full event", MqttPayloadOptions{}, nil, event, string(fullEvent), true},
+		{"compact", MqttPayloadOptions{PayloadFormat: MqttPayloadFormatCompact}, &notification, notification,
+			`{"id":"n1","title":"title","message":"message","topic":"incident","severity":"error","isRead":false,"created_at":"2026-01-02T03:04:05Z"}`, true},
+		{"compact event", MqttPayloadOptions{PayloadFormat: MqttPayloadFormatCompact}, nil, event, string(fullEvent), true},
+		{"plain", MqttPayloadOptions{PayloadFormat: MqttPayloadFormatPlain}, &notification, notification, "title\nmessage", true},
+		{"plain event", MqttPayloadOptions{PayloadFormat: MqttPayloadFormatPlain}, nil, event, "", false},
+		{"template", MqttPayloadOptions{PayloadFormat: MqttPayloadFormatTemplate, PayloadTemplate: "{{.Severity}}: {{.Title}}"}, &notification, notification, "error: title", true},
+		{"template event", MqttPayloadOptions{PayloadFormat: MqttPayloadFormatTemplate, PayloadTemplate: "{{.Title}}"}, nil, event, "", false},
+	}
+	for _, c := range cases {
+		actual, ok, err := c.options.Render(c.notification, c.payload)
+		if err != nil {
+			t.Error(c.name, err)
+			continue
+		}
+		if ok != c.ok || actual != c.expected {
+			t.Error(c.name, ok, actual, c.expected)
+		}
+	}
+}
+
+func TestMqttPayloadOptionsValidate(t *testing.T) {
+	valid := []MqttPayloadOptions{
+		{},
+		{PayloadFormat: MqttPayloadFormatPlain, Retain: true},
+		{PayloadFormat: MqttPayloadFormatTemplate, PayloadTemplate: "{{.Title}}"},
+	}
+	for _, options := range valid {
+		if err := options.Validate(); err != nil {
+			t.Error(options, err)
+		}
+	}
+	invalid := []MqttPayloadOptions{
+		{PayloadFormat: "xml"},
+		{PayloadFormat: MqttPayloadFormatTemplate},
+		{PayloadFormat: MqttPayloadFormatTemplate, PayloadTemplate: "{{.Title"},
+		{PayloadFormat: MqttPayloadFormatTemplate, PayloadTemplate: "{{range 100000000000}}x{{end}}"},
+		{PayloadFormat: MqttPayloadFormatTemplate, PayloadTemplate: "{{printf \"%0999999999d\" 1}}"},
+	}
+	for _, options := range invalid {
+		if err := options.Validate(); err == nil {
+			t.Error("expected error", options)
+		}
+	}
+}
+
+func TestMqttPayloadOptionsRenderSizeLimit(t *testing.T) {
+	options := MqttPayloadOptions{PayloadFormat: MqttPayloadFormatTemplate, PayloadTemplate: "{{.Message}}{{.Message}}"}
+	notification := Notification{Message: strings.Repeat("x", maxTemplatePayloadSize/2+1)}
+	_, _, err := options.Render(&notification, notification)
+	if err == nil {
+		t.Error("expected size limit error")
+	}
+}
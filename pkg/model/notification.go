@@ -18,7 +18,13 @@ package model
 
 import (
 	"crypto/sha256"
+	"errors"
+	"fmt"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
 	"time"
 )
 
@@ -48,15 +54,54 @@ func AllTopics() []Topic {
 	}
 }
 
+type Severity = string
+
+const SeverityInfo = "info"
+const SeverityWarning = "warning"
+const SeverityError = "error"
+const SeverityCritical = "critical"
+
+// AllSeverities returns all severities ordered from lowest to highest
+func AllSeverities() []Severity {
+	return []Severity{
+		SeverityInfo,
+		SeverityWarning,
+		SeverityError,
+		SeverityCritical,
+	}
+}
+
+// SeverityAtLeast reports whether severity is equal to or higher than min. Empty or unknown severities are treated as SeverityInfo.
+func SeverityAtLeast(severity Severity, min Severity) bool {
+	return max(slices.Index(AllSeverities(), severity), 0) >= max(slices.Index(AllSeverities(), min), 0)
+}
+
+// NotificationAction is rendered as button by clients. Either Url or CallbackId is set.
+type NotificationAction struct {
+	Label      string `json:"label" bson:"label"`
+	Url        string `json:"url,omitempty" bson:"url,omitempty"`
+	CallbackId string `json:"callback_id,omitempty" bson:"callback_id,omitempty"`
+}
+
 type Notification struct {
-	Id        string    `json:"_id" bson:"_id"`
-	UserId    string    `json:"userId" bson:"userId"`
-	Title     string    `json:"title" bson:"title"`
-	Message   string    `json:"message" bson:"message"`
-	IsRead    bool      `json:"isRead" bson:"isRead"`
-	CreatedAt time.Time `json:"created_at" bson:"created_at"`
-	Topic     `json:"topic" bson:"topic"`
-	hash      [32]byte
+	Id             string     `json:"_id" bson:"_id"`
+	UserId         string     `json:"userId" bson:"userId"`
+	Title          string     `json:"title" bson:"title"`
+	Message        string     `json:"message" bson:"message"`
+	IsRead         bool       `json:"isRead" bson:"isRead"`
+	CreatedAt      time.Time  `json:"created_at" bson:"created_at"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
+	DeliverAt      *time.Time `json:"deliver_at,omitempty" bson:"deliver_at,omitempty"` // only set while the notification waits for its scheduled delivery
+	Topic          `json:"topic" bson:"topic"`
+	Severity       Severity             `json:"severity" bson:"severity"`
+	Link           string               `json:"link,omitempty" bson:"link,omitempty"` // deep link into the platform ui
+	Actions        []NotificationAction `json:"actions,omitempty" bson:"actions,omitempty"`
+	Metadata       map[string]string    `json:"metadata,omitempty" bson:"metadata,omitempty"`               // machine-readable context like device or process instance ids
+	CorrelationKey string               `json:"correlation_key,omitempty" bson:"correlation_key,omitempty"` // notifications with the same key replace each other instead of piling up
+	Occurrences    int64                `json:"occurrences,omitempty" bson:"occurrences,omitempty"`
+	LastOccurredAt *time.Time           `json:"last_occurred_at,omitempty" bson:"last_occurred_at,omitempty"` // set when a duplicate was suppressed
+	ResolvedAt     *time.Time           `json:"resolved_at,omitempty" bson:"resolved_at,omitempty"`
+	hash           [32]byte
 }
 
 func (n *Notification) ToDB() (db NotificationDB, err error) {
@@ -65,6 +110,8 @@ func (n *Notification) ToDB() (db NotificationDB, err error) {
 		return db, err
 	}
 	db.UserId, db.Title, db.Message, db.IsRead, db.CreatedAt, db.Topic, db.Hash = n.UserId, n.Title, n.Message, n.IsRead, n.CreatedAt, n.Topic, n.Hash()
+	db.ExpiresAt, db.DeliverAt, db.Severity, db.Link, db.Actions, db.Metadata = n.ExpiresAt, n.DeliverAt, n.Severity, n.Link, n.Actions, n.Metadata
+	db.CorrelationKey, db.Occurrences, db.LastOccurredAt, db.ResolvedAt = n.CorrelationKey, n.Occurrences, n.LastOccurredAt, n.ResolvedAt
 	return
 }
 
@@ -79,7 +126,74 @@ func (n *Notification) Equal(other interface{}) bool {
 		n.Title == otherN.Title &&
 		n.Message == otherN.Message &&
 		n.UserId == otherN.UserId &&
-		n.Topic == otherN.Topic
+		n.Topic == otherN.Topic &&
+		n.Severity == otherN.Severity &&
+		n.Link == otherN.Link &&
+		slices.Equal(n.Actions, otherN.Actions) &&
+		maps.Equal(n.Metadata, otherN.Metadata) &&
+		timePtrEqual(n.ExpiresAt, otherN.ExpiresAt) &&
+		timePtrEqual(n.DeliverAt, otherN.DeliverAt) &&
+		n.CorrelationKey == otherN.CorrelationKey &&
+		n.Occurrences == otherN.Occurrences &&
+		timePtrEqual(n.LastOccurredAt, otherN.LastOccurredAt) &&
+		timePtrEqual(n.ResolvedAt, otherN.ResolvedAt)
+}
+
+func timePtrEqual(a *time.Time, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// ValidateLinks checks the link and the actions of the notification
+func (n *Notification) ValidateLinks() error {
+	if n.Link != "" {
+		err := ValidateLinkUrl(n.Link)
+		if err != nil {
+			return err
+		}
+	}
+	for _, action := range n.Actions {
+		if action.Label == "" {
+			return errors.New("action label may not be empty")
+		}
+		if (action.Url == "") == (action.CallbackId == "") {
+			return errors.New("action needs either url or callback_id")
+		}
+		if action.Url != "" {
+			err := ValidateLinkUrl(action.Url)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateLinkUrl accepts absolute http(s) urls and paths relative to the platform ui.
+// links end up in email hrefs, other schemes like javascript: or data: are rejected.
+func ValidateLinkUrl(link string) error {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return fmt.Errorf("invalid url '%s': %w", link, err)
+	}
+	switch {
+	case parsed.Scheme == "" && parsed.Host == "":
+		return nil
+	case (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != "":
+		return nil
+	default:
+		return fmt.Errorf("invalid url '%s': only http(s) urls and relative paths are allowed", link)
+	}
+}
+
+// ValidateMetadataKey rejects keys that can not be used as mongo field names and would break label filtering
+func ValidateMetadataKey(key string) error {
+	if key == "" || strings.ContainsAny(key, ".$") {
+		return fmt.Errorf("invalid metadata key '%s'", key)
+	}
+	return nil
 }
 
 func (n *Notification) Hash() [32]byte {
@@ -93,20 +207,31 @@ func (n *Notification) Hash() [32]byte {
 }
 
 type NotificationDB struct {
-	Id        primitive.ObjectID `bson:"_id"`
-	UserId    string             `bson:"userId"`
-	Title     string             `bson:"title"`
-	Message   string             `bson:"message"`
-	IsRead    bool               `bson:"isRead"`
-	CreatedAt time.Time          `bson:"created_at"`
-	Topic     `bson:"topic"`
-	Hash      [32]byte `bson:"hash"`
+	Id             primitive.ObjectID `bson:"_id"`
+	UserId         string             `bson:"userId"`
+	Title          string             `bson:"title"`
+	Message        string             `bson:"message"`
+	IsRead         bool               `bson:"isRead"`
+	CreatedAt      time.Time          `bson:"created_at"`
+	ExpiresAt      *time.Time         `bson:"expires_at,omitempty"`
+	DeliverAt      *time.Time         `bson:"deliver_at,omitempty"`
+	Topic          `bson:"topic"`
+	Severity       Severity             `bson:"severity"`
+	Link           string               `bson:"link,omitempty"`
+	Actions        []NotificationAction `bson:"actions,omitempty"`
+	Metadata       map[string]string    `bson:"metadata,omitempty"`
+	CorrelationKey string               `bson:"correlation_key,omitempty"`
+	Occurrences    int64                `bson:"occurrences,omitempty"`
+	LastOccurredAt *time.Time           `bson:"last_occurred_at,omitempty"`
+	ResolvedAt     *time.Time           `bson:"resolved_at,omitempty"`
+	Hash           [32]byte             `bson:"hash"`
 }
 
 type NotificationList struct {
-	Total         int64          `json:"total"`
+	Total         int64          `json:"total"` // -1 if not counted
 	Limit         int            `json:"limit"`
 	Offset        int            `json:"offset"`
+	NextCursor    string         `json:"next_cursor,omitempty"`
 	Notifications []Notification `json:"notifications"`
 }
 
@@ -115,7 +240,7 @@ func (n *NotificationList) Equal(other interface{}) bool {
 	if !ok {
 		return false
 	}
-	if n.Offset != otherL.Offset || n.Limit != otherL.Limit || n.Total != otherL.Total {
+	if n.Offset != otherL.Offset || n.Limit != otherL.Limit || n.Total != otherL.Total || n.NextCursor != otherL.NextCursor {
 		return false
 	}
 	for i := range n.Notifications {
@@ -126,6 +251,42 @@ func (n *NotificationList) Equal(other interface{}) bool {
 	return true
 }
 
+type NotificationFilter struct {
+	Topics        []Topic    `json:"topics,omitempty"`
+	CreatedAfter  *time.Time `json:"created_after,omitempty"`
+	CreatedBefore *time.Time `json:"created_before,omitempty"`
+}
+
+// NotificationReadStateRequest sets the read state either for the listed ids or for all notifications matching the filter
+type NotificationReadStateRequest struct {
+	IsRead bool                `json:"isRead"`
+	Ids    []string            `json:"ids,omitempty"`
+	Filter *NotificationFilter `json:"filter,omitempty"`
+}
+
+type NotificationReadState struct {
+	IsRead bool     `json:"isRead"`
+	Ids    []string `json:"ids"`
+}
+
+// NotificationResolveRequest resolves the notification with the given correlation key.
+// Resolved notifications are either marked with resolved_at or deleted.
+type NotificationResolveRequest struct {
+	UserId         string `json:"userId"`
+	CorrelationKey string `json:"correlation_key"`
+	Delete         bool   `json:"delete"`
+}
+
+type NotificationCount struct {
+	Total  int64 `json:"total"`
+	Unread int64 `json:"unread"`
+}
+
+type NotificationCounts struct {
+	NotificationCount
+	Topics map[Topic]NotificationCount `json:"topics"`
+}
+
 type EventMessage struct {
 	Type    string      `json:"type"`
 	Payload interface{} `json:"payload,omitempty"`
@@ -139,3 +300,5 @@ const WsRefreshType = "refresh"
 const WsListType = "notification list"
 const WsUpdateDeleteType = "delete notification"
 const WsUpdateDeleteManyType = "delete notifications"
+const WsCountType = "notification count"
+const WsUpdateReadStateManyType = "set notifications read state"
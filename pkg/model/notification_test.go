@@ -0,0 +1,76 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import "testing"
+
+func TestSeverityAtLeast(t *testing.T) {
+	cases := []struct {
+		severity Severity
+		min      Severity
+		expected bool
+	}{
+		{SeverityInfo, SeverityInfo, true},
+		{SeverityWarning, SeverityInfo, true},
+		{SeverityCritical, SeverityError, true},
+		{SeverityError, SeverityCritical, false},
+		{SeverityInfo, SeverityWarning, false},
+		{"", SeverityInfo, true},
+		{"", SeverityWarning, false},
+		{"unknown", SeverityInfo, true},
+		{SeverityInfo, "", true},
+	}
+	for _, c := range cases {
+		actual := SeverityAtLeast(c.severity, c.min)
+		if actual != c.expected {
+			t.Errorf("SeverityAtLeast(%q, %q): expected %v, got %v", c.severity, c.min, c.expected, actual)
+		}
+	}
+}
+
+func TestNotificationValidateLinks(t *testing.T) {
+	cases := []struct {
+		name  string
+		link  string
+		url   string
+		valid bool
+	}{
+		{"https", "https://example.com/devices/1", "", true},
+		{"http", "", "http://example.com", true},
+		{"absolute path", "/devices/1", "", true},
+		{"relative path", "devices/1?tab=info", "", true},
+		{"javascript link", "javascript:alert(1)", "", false},
+		{"javascript link upper case", "JavaScript:alert(1)", "", false},
+		{"javascript link with leading space", " javascript:alert(1)", "", false},
+		{"data action", "", "data:text/html;base64,PHNjcmlwdD4=", false},
+		{"protocol relative", "//example.com", "", false},
+		{"ftp", "ftp://example.com/file", "", false},
+		{"http without host", "http:/devices", "", false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			notification := Notification{Link: c.link}
+			if c.url != "" {
+				notification.Actions = []NotificationAction{{Label: "open", Url: c.url}}
+			}
+			err := notification.ValidateLinks()
+			if (err == nil) != c.valid {
+				t.Error("expected valid", c.valid, "got", err)
+			}
+		})
+	}
+}
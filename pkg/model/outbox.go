@@ -0,0 +1,42 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import "time"
+
+type OutboxJobState = string
+
+const OutboxJobPending = "pending"
+const OutboxJobDone = "done"
+const OutboxJobDeadLetter = "dead_letter" // max attempts reached, no further retries
+
+// OutboxJob is the persisted delivery of a notification on one channel.
+// the notification is read when the job runs, so that retries deliver its current state.
+// targets that already received the notification are skipped by retries.
+type OutboxJob struct {
+	Id               string         `json:"id" bson:"_id"`
+	UserId           string         `json:"-" bson:"user_id"`
+	NotificationId   string         `json:"notification_id" bson:"notification_id"`
+	Channel          Channel        `json:"channel" bson:"channel"`
+	State            OutboxJobState `json:"state" bson:"state"`
+	Attempts         int            `json:"attempts" bson:"attempts"`
+	LastError        string         `json:"last_error,omitempty" bson:"last_error,omitempty"`
+	DeliveredTargets []string       `json:"delivered_targets,omitempty" bson:"delivered_targets,omitempty"` // see DeliveryAttempt.Target
+	CreatedAt        time.Time      `json:"created_at" bson:"created_at"`
+	NextAttemptAt    time.Time      `json:"next_attempt_at" bson:"next_attempt_at"` // also used as lease while a worker runs the job
+	FinishedAt       *time.Time     `json:"finished_at,omitempty" bson:"finished_at,omitempty"`
+}
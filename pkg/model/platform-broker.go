@@ -19,4 +19,6 @@ package model
 type PlatformBroker struct {
 	Enabled bool   `json:"enabled"`
 	UserId  string `json:"-" bson:"user_id"`
+
+	MqttPayloadOptions `bson:",inline"`
 }
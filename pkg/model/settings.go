@@ -16,6 +16,14 @@
 
 package model
 
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+	_ "time/tzdata" // the alpine image ships without zoneinfo
+)
+
 type Channel = string
 
 const ChannelWebsocket = "websoket"
@@ -33,8 +41,125 @@ func AllChannels() []Channel {
 }
 
 type Settings struct {
-	UserId             string              `json:"-" bson:"user_id"`
-	ChannelTopicConfig map[Channel][]Topic `json:"channel_topic_config" bson:"channel_topic_config"`
+	UserId             string                 `json:"-" bson:"user_id"`
+	ChannelTopicConfig map[Channel][]Topic    `json:"channel_topic_config" bson:"channel_topic_config"`
+	ChannelMinSeverity map[Channel]Severity   `json:"channel_min_severity,omitempty" bson:"channel_min_severity,omitempty"` // channels without entry receive all severities
+	RetentionDays      map[Topic]int          `json:"retention_days,omitempty" bson:"retention_days,omitempty"`             // notifications of a topic are deleted after the given number of days, topics without entry are kept forever
+	QuietHours         map[Channel]QuietHours `json:"quiet_hours,omitempty" bson:"quiet_hours,omitempty"`                   // only supported for ChannelFcm and ChannelEmail
+	EmailDelivery      EmailDelivery          `json:"email_delivery,omitempty" bson:"email_delivery,omitempty"`             // defaults to EmailDeliveryImmediate
+}
+
+type EmailDelivery = string
+
+const EmailDeliveryImmediate = "immediate"
+const EmailDeliveryHourly = "hourly"
+const EmailDeliveryDaily = "daily"
+
+func AllEmailDeliveries() []EmailDelivery {
+	return []EmailDelivery{
+		EmailDeliveryImmediate,
+		EmailDeliveryHourly,
+		EmailDeliveryDaily,
+	}
+}
+
+// NextEmailDigest returns when the digest collecting a notification received at now is due.
+// hourly digests are sent at the full hour, daily digests at midnight UTC.
+func (s Settings) NextEmailDigest(now time.Time) (due time.Time, digest bool) {
+	now = now.UTC()
+	switch s.EmailDelivery {
+	case EmailDeliveryHourly:
+		return now.Truncate(time.Hour).Add(time.Hour), true
+	case EmailDeliveryDaily:
+		return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC), true
+	default:
+		return due, false
+	}
+}
+
+// QuietHours is a daily window in which a channel does not deliver notifications.
+// the window may span midnight (e.g. 22:00 - 07:00).
+type QuietHours struct {
+	Start          string  `json:"start" bson:"start"`                                         // local time as 15:04
+	End            string  `json:"end" bson:"end"`                                             // local time as 15:04
+	TimeZone       string  `json:"time_zone" bson:"time_zone"`                                 // IANA name like Europe/Berlin, defaults to UTC
+	Defer          bool    `json:"defer" bson:"defer"`                                         // deliver suppressed notifications when the window ends instead of dropping them
+	OverrideTopics []Topic `json:"override_topics,omitempty" bson:"override_topics,omitempty"` // topics that are delivered during quiet hours
+}
+
+const quietHoursLayout = "15:04"
+
+func QuietHoursChannels() []Channel {
+	return []Channel{ChannelFcm, ChannelEmail}
+}
+
+func (q QuietHours) Validate() error {
+	_, err := time.Parse(quietHoursLayout, q.Start)
+	if err != nil {
+		return fmt.Errorf("invalid quiet hours start '%s'", q.Start)
+	}
+	_, err = time.Parse(quietHoursLayout, q.End)
+	if err != nil {
+		return fmt.Errorf("invalid quiet hours end '%s'", q.End)
+	}
+	if q.Start == q.End {
+		return errors.New("quiet hours start and end may not be equal")
+	}
+	_, err = time.LoadLocation(q.TimeZone)
+	if err != nil {
+		return fmt.Errorf("unknown time zone '%s'", q.TimeZone)
+	}
+	return nil
+}
+
+// ActiveUntil reports whether now is inside the window and when the window ends
+func (q QuietHours) ActiveUntil(now time.Time) (end time.Time, active bool) {
+	location, err := time.LoadLocation(q.TimeZone)
+	if err != nil {
+		return end, false
+	}
+	start, err := time.Parse(quietHoursLayout, q.Start)
+	if err != nil {
+		return end, false
+	}
+	stop, err := time.Parse(quietHoursLayout, q.End)
+	if err != nil {
+		return end, false
+	}
+	local := now.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	stopMinute := stop.Hour()*60 + stop.Minute()
+	endDay := local
+	switch {
+	case startMinute < stopMinute && minute >= startMinute && minute < stopMinute:
+	case startMinute > stopMinute && minute >= startMinute:
+		endDay = local.AddDate(0, 0, 1)
+	case startMinute > stopMinute && minute < stopMinute:
+	default:
+		return end, false
+	}
+	return time.Date(endDay.Year(), endDay.Month(), endDay.Day(), stop.Hour(), stop.Minute(), 0, 0, location), true
+}
+
+// QuietUntil reports whether the channel is in quiet hours for the notification and when they end
+func (s Settings) QuietUntil(channel Channel, notification Notification, now time.Time) (end time.Time, quiet bool, deferDelivery bool) {
+	quietHours, ok := s.QuietHours[channel]
+	if !ok || slices.Contains(quietHours.OverrideTopics, notification.Topic) {
+		return end, false, false
+	}
+	end, quiet = quietHours.ActiveUntil(now)
+	return end, quiet, quietHours.Defer
+}
+
+// ChannelEnabled reports whether the notification should be delivered on the channel, considering topic and minimum severity
+func (s Settings) ChannelEnabled(channel Channel, notification Notification) bool {
+	topics, ok := s.ChannelTopicConfig[channel]
+	if ok && !slices.Contains(topics, notification.Topic) {
+		return false
+	}
+	minSeverity, ok := s.ChannelMinSeverity[channel]
+	return !ok || SeverityAtLeast(notification.Severity, minSeverity)
 }
 
 func DefaultSettings() Settings {
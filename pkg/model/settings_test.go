@@ -0,0 +1,125 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuietHoursActiveUntil(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	overnight := QuietHours{Start: "22:00", End: "07:00", TimeZone: "Europe/Berlin"}
+	daytime := QuietHours{Start: "12:00", End: "13:30", TimeZone: "Europe/Berlin"}
+
+	cases := []struct {
+		name       string
+		quietHours QuietHours
+		now        time.Time
+		active     bool
+		end        time.Time
+	}{
+		{"overnight before start", overnight, time.Date(2026, 3, 10, 21, 59, 0, 0, berlin), false, time.Time{}},
+		{"overnight evening", overnight, time.Date(2026, 3, 10, 23, 0, 0, 0, berlin), true, time.Date(2026, 3, 11, 7, 0, 0, 0, berlin)},
+		{"overnight morning", overnight, time.Date(2026, 3, 11, 3, 0, 0, 0, berlin), true, time.Date(2026, 3, 11, 7, 0, 0, 0, berlin)},
+		{"overnight end", overnight, time.Date(2026, 3, 11, 7, 0, 0, 0, berlin), false, time.Time{}},
+		{"overnight in utc", overnight, time.Date(2026, 3, 11, 2, 0, 0, 0, time.UTC), true, time.Date(2026, 3, 11, 7, 0, 0, 0, berlin)},
+		{"daytime inside", daytime, time.Date(2026, 3, 10, 13, 0, 0, 0, berlin), true, time.Date(2026, 3, 10, 13, 30, 0, 0, berlin)},
+		{"daytime after", daytime, time.Date(2026, 3, 10, 13, 30, 0, 0, berlin), false, time.Time{}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			end, active := c.quietHours.ActiveUntil(c.now)
+			if active != c.active {
+				t.Error("expected active", c.active, "got", active)
+			}
+			if active && !end.Equal(c.end) {
+				t.Error("expected end", c.end, "got", end)
+			}
+		})
+	}
+}
+
+func TestSettingsQuietUntilOverrideTopics(t *testing.T) {
+	settings := Settings{QuietHours: map[Channel]QuietHours{
+		ChannelFcm: {Start: "00:00", End: "23:59", OverrideTopics: []Topic{TopicIncident}},
+	}}
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	_, quiet, _ := settings.QuietUntil(ChannelFcm, Notification{Topic: TopicDeviceOffline}, now)
+	if !quiet {
+		t.Error("expected device_offline to be suppressed")
+	}
+	_, quiet, _ = settings.QuietUntil(ChannelFcm, Notification{Topic: TopicIncident}, now)
+	if quiet {
+		t.Error("expected incident to break through")
+	}
+	_, quiet, _ = settings.QuietUntil(ChannelEmail, Notification{Topic: TopicDeviceOffline}, now)
+	if quiet {
+		t.Error("expected email without quiet hours to be delivered")
+	}
+}
+
+func TestSettingsNextEmailDigest(t *testing.T) {
+	now := time.Date(2026, 3, 10, 23, 15, 0, 0, time.UTC)
+	_, digest := Settings{}.NextEmailDigest(now)
+	if digest {
+		t.Error("expected immediate delivery by default")
+	}
+	due, digest := Settings{EmailDelivery: EmailDeliveryHourly}.NextEmailDigest(now)
+	if !digest || !due.Equal(time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Error("unexpected hourly digest", due, digest)
+	}
+	due, digest = Settings{EmailDelivery: EmailDeliveryDaily}.NextEmailDigest(now)
+	if !digest || !due.Equal(time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC)) {
+		t.Error("unexpected daily digest", due, digest)
+	}
+	due, _ = Settings{EmailDelivery: EmailDeliveryDaily}.NextEmailDigest(now.Add(time.Hour))
+	if !due.Equal(time.Date(2026, 3, 12, 0, 0, 0, 0, time.UTC)) {
+		t.Error("unexpected daily digest", due)
+	}
+}
+
+func TestSettingsChannelEnabled(t *testing.T) {
+	settings := DefaultSettings()
+	settings.ChannelMinSeverity = map[Channel]Severity{ChannelMqtt: SeverityWarning, ChannelEmail: SeverityInfo}
+
+	cases := []struct {
+		name         string
+		channel      Channel
+		notification Notification
+		expected     bool
+	}{
+		{"below min severity", ChannelMqtt, Notification{Topic: TopicDeveloper, Severity: SeverityInfo}, false},
+		{"at min severity", ChannelMqtt, Notification{Topic: TopicDeveloper, Severity: SeverityWarning}, true},
+		{"above min severity", ChannelMqtt, Notification{Topic: TopicDeveloper, Severity: SeverityCritical}, true},
+		{"missing severity below min", ChannelMqtt, Notification{Topic: TopicDeveloper}, false},
+		{"missing severity with min info", ChannelEmail, Notification{Topic: TopicDeveloper}, true},
+		{"channel without min severity", ChannelWebsocket, Notification{Topic: TopicDeveloper}, true},
+		{"topic disabled", ChannelFcm, Notification{Topic: TopicDeveloper, Severity: SeverityCritical}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actual := settings.ChannelEnabled(c.channel, c.notification)
+			if actual != c.expected {
+				t.Error("expected", c.expected, "got", actual)
+			}
+		})
+	}
+}
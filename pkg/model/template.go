@@ -0,0 +1,152 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+)
+
+// user supplied templates may only print values and use conditions. loops, nested templates
+// and formatting functions are rejected, so that rendering is bounded by the size of the notification.
+var allowedTemplateFuncs = []string{"and", "or", "not", "eq", "ne", "lt", "le", "gt", "ge", "len", "index", "html", "js", "urlquery"}
+
+var errTemplateOutputTooLarge = errors.New("rendered template exceeds size limit")
+
+// parsed templates are cached by their text, so that publishing does not parse them again
+const userTemplateCacheSize = 1000
+
+var userTemplateCache = struct {
+	mux       sync.Mutex
+	templates map[string]*template.Template
+}{templates: map[string]*template.Template{}}
+
+// parseUserTemplate parses and checks the user supplied template
+func parseUserTemplate(text string) (*template.Template, error) {
+	userTemplateCache.mux.Lock()
+	tmpl, ok := userTemplateCache.templates[text]
+	userTemplateCache.mux.Unlock()
+	if ok {
+		return tmpl, nil
+	}
+	tmpl, err := template.New("user").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	if len(tmpl.Templates()) > 1 {
+		return nil, errors.New("template definitions are not allowed")
+	}
+	err = checkTemplateNode(tmpl.Tree.Root)
+	if err != nil {
+		return nil, err
+	}
+	userTemplateCache.mux.Lock()
+	if len(userTemplateCache.templates) >= userTemplateCacheSize {
+		clear(userTemplateCache.templates)
+	}
+	userTemplateCache.templates[text] = tmpl
+	userTemplateCache.mux.Unlock()
+	return tmpl, nil
+}
+
+// renderUserTemplate executes the template, failing if the result exceeds limit bytes
+func renderUserTemplate(tmpl *template.Template, data interface{}, limit int) (string, error) {
+	writer := &limitedWriter{limit: limit}
+	err := tmpl.Execute(writer, data)
+	if err != nil {
+		return "", err
+	}
+	return writer.builder.String(), nil
+}
+
+func checkTemplateNode(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			err := checkTemplateNode(child)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case *parse.TextNode, *parse.CommentNode:
+		return nil
+	case *parse.ActionNode:
+		return checkTemplateArg(n.Pipe)
+	case *parse.IfNode:
+		err := checkTemplateArg(n.Pipe)
+		if err != nil {
+			return err
+		}
+		err = checkTemplateNode(n.List)
+		if err != nil {
+			return err
+		}
+		return checkTemplateNode(n.ElseList)
+	default:
+		return fmt.Errorf("%s is not allowed in templates", node)
+	}
+}
+
+func checkTemplateArg(node parse.Node) error {
+	switch n := node.(type) {
+	case *parse.PipeNode:
+		if n == nil {
+			return nil
+		}
+		for _, cmd := range n.Cmds {
+			for _, arg := range cmd.Args {
+				err := checkTemplateArg(arg)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	case *parse.IdentifierNode:
+		if !slices.Contains(allowedTemplateFuncs, n.Ident) {
+			return fmt.Errorf("function %s is not allowed in templates", n.Ident)
+		}
+		return nil
+	case *parse.ChainNode:
+		return checkTemplateArg(n.Node)
+	case *parse.FieldNode, *parse.DotNode, *parse.VariableNode, *parse.StringNode, *parse.NumberNode, *parse.BoolNode, *parse.NilNode:
+		return nil
+	default:
+		return fmt.Errorf("%s is not allowed in templates", node)
+	}
+}
+
+type limitedWriter struct {
+	builder strings.Builder
+	limit   int
+}
+
+func (this *limitedWriter) Write(p []byte) (int, error) {
+	if this.builder.Len()+len(p) > this.limit {
+		return 0, errTemplateOutputTooLarge
+	}
+	return this.builder.Write(p)
+}
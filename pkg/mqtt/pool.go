@@ -0,0 +1,211 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqtt
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PoolKey identifies a pooled connection. Connections are looked up by BrokerId;
+// if any other field differs, the broker changed and the connection is replaced.
+// This also covers changes made through other instances, which can not invalidate the local pool.
+type PoolKey struct {
+	BrokerId string
+	Address  string
+	User     string
+	Password string
+	Qos      uint8
+	TLS      TLSOptions
+	V5       bool
+}
+
+// MessagePublisher is implemented by Publisher and PublisherV5
+type MessagePublisher interface {
+	PublishMessage(msg Message) error
+}
+
+// Connect creates a publisher for the key, using MQTT v5 if requested. The connection is closed when ctx is done.
+func Connect(ctx context.Context, key PoolKey, client string, debug bool) (MessagePublisher, error) {
+	if key.V5 {
+		return NewPublisherV5(ctx, key.Address, key.User, key.Password, client, key.Qos, key.TLS, debug)
+	}
+	return NewTLSPublisher(ctx, key.Address, key.User, key.Password, client, key.Qos, key.TLS, debug)
+}
+
+// Pool keeps long-lived publishers to user brokers, so that notifications do not need a new connection each.
+type Pool struct {
+	ctx          context.Context
+	clientPrefix string
+	debug        bool
+	idleTimeout  time.Duration
+	slots        chan struct{}
+	mux          sync.Mutex
+	entries      map[string]*poolEntry
+}
+
+type poolEntry struct {
+	key       PoolKey
+	mux       sync.Mutex
+	publisher MessagePublisher
+	cancel    context.CancelFunc
+	closed    bool // removed from the pool, may not be connected again
+	lastUsed  atomic.Int64
+}
+
+// NewPool creates a pool that disconnects publishers unused for idleTimeout
+// and allows at most maxConcurrent publishes at the same time.
+// Connects are not limited, so that unreachable brokers do not hold back publishes to other brokers.
+func NewPool(ctx context.Context, clientPrefix string, idleTimeout time.Duration, maxConcurrent int, debug bool) *Pool {
+	pool := &Pool{
+		ctx:          ctx,
+		clientPrefix: clientPrefix,
+		debug:        debug,
+		idleTimeout:  idleTimeout,
+		slots:        make(chan struct{}, max(maxConcurrent, 1)),
+		entries:      map[string]*poolEntry{},
+	}
+	go pool.evictIdle()
+	return pool
+}
+
+func (this *Pool) Publish(key PoolKey, msg Message) error {
+	publisher, err := this.get(key)
+	if err != nil {
+		return err
+	}
+	select {
+	case this.slots <- struct{}{}:
+	case <-this.ctx.Done():
+		return this.ctx.Err()
+	}
+	err = publisher.PublishMessage(msg)
+	<-this.slots
+	if err != nil {
+		this.Invalidate(key.BrokerId) // the next publish connects again
+	}
+	return err
+}
+
+// Invalidate disconnects the publisher of the broker; used when a broker is changed or removed
+func (this *Pool) Invalidate(brokerId string) {
+	this.mux.Lock()
+	entry, ok := this.entries[brokerId]
+	delete(this.entries, brokerId)
+	this.mux.Unlock()
+	if ok {
+		entry.close()
+	}
+}
+
+// get returns the connected publisher of the key. entries closed while waiting for their lock
+// have been removed from the pool, connecting them would leak the connection; the lookup is repeated instead.
+func (this *Pool) get(key PoolKey) (MessagePublisher, error) {
+	for {
+		entry := this.entry(key)
+		entry.lastUsed.Store(time.Now().UnixNano())
+		entry.mux.Lock()
+		if entry.closed {
+			entry.mux.Unlock()
+			continue
+		}
+		if entry.publisher != nil {
+			entry.mux.Unlock()
+			return entry.publisher, nil
+		}
+		ctx, cancel := context.WithCancel(this.ctx)
+		publisher, err := Connect(ctx, key, this.clientPrefix+uuid.NewString(), this.debug)
+		if err != nil {
+			cancel()
+			entry.closed = true
+			entry.mux.Unlock()
+			this.remove(entry)
+			return nil, err
+		}
+		entry.publisher, entry.cancel = publisher, cancel
+		entry.mux.Unlock()
+		return publisher, nil
+	}
+}
+
+// entry returns the pooled entry of the broker, replacing it if the key changed
+func (this *Pool) entry(key PoolKey) *poolEntry {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	entry, ok := this.entries[key.BrokerId]
+	if ok && entry.key != key {
+		delete(this.entries, key.BrokerId)
+		go entry.close()
+		ok = false
+	}
+	if !ok {
+		entry = &poolEntry{key: key}
+		this.entries[key.BrokerId] = entry
+	}
+	return entry
+}
+
+// remove drops the entry if it is still the pooled one of its broker
+func (this *Pool) remove(entry *poolEntry) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.entries[entry.key.BrokerId] == entry {
+		delete(this.entries, entry.key.BrokerId)
+	}
+}
+
+func (this *Pool) evictIdle() {
+	ticker := time.NewTicker(max(this.idleTimeout/2, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-this.ctx.Done():
+			return
+		case <-ticker.C:
+			idleSince := time.Now().Add(-this.idleTimeout).UnixNano()
+			evicted := []*poolEntry{}
+			this.mux.Lock()
+			for brokerId, entry := range this.entries {
+				if entry.lastUsed.Load() < idleSince {
+					delete(this.entries, brokerId)
+					evicted = append(evicted, entry)
+				}
+			}
+			this.mux.Unlock()
+			for _, entry := range evicted {
+				entry.close()
+			}
+			if this.debug && len(evicted) > 0 {
+				log.Println("DEBUG: evicted", len(evicted), "idle mqtt connections")
+			}
+		}
+	}
+}
+
+func (this *poolEntry) close() {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.cancel != nil {
+		this.cancel()
+	}
+	this.publisher, this.cancel, this.closed = nil, nil, true
+}
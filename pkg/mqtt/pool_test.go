@@ -0,0 +1,77 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqtt
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPoolUnreachableBroker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool := NewPool(ctx, "test-", time.Minute, 1, false)
+	for _, key := range []PoolKey{
+		{BrokerId: "broker1", Address: "127.0.0.1:1"},
+		{BrokerId: "broker1", Address: "127.0.0.1:1"},
+		{BrokerId: "broker2", Address: "127.0.0.1:1", V5: true},
+	} {
+		err := pool.Publish(key, Message{Topic: "topic", Payload: "msg"})
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		pool.mux.Lock()
+		entries := len(pool.entries)
+		pool.mux.Unlock()
+		if entries != 0 {
+			t.Fatal("failed connection kept in pool")
+		}
+	}
+}
+
+func TestPoolClosedEntryIsNotReconnected(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	pool := NewPool(ctx, "test-", time.Minute, 1, false)
+	key := PoolKey{BrokerId: "broker1", Address: "127.0.0.1:1"}
+
+	// a publish holds the entry while it is removed from the pool
+	closed := pool.entry(key)
+	closed.mux.Lock()
+	done := make(chan error)
+	go func() {
+		_, err := pool.get(key)
+		done <- err
+	}()
+	time.Sleep(100 * time.Millisecond)
+	pool.mux.Lock()
+	delete(pool.entries, key.BrokerId)
+	pool.mux.Unlock()
+	closed.closed = true
+	closed.mux.Unlock()
+
+	err := <-done
+	if err == nil {
+		t.Fatal("expected connect error of new entry")
+	}
+	closed.mux.Lock()
+	defer closed.mux.Unlock()
+	if closed.publisher != nil || closed.cancel != nil {
+		t.Fatal("closed entry was connected again")
+	}
+}
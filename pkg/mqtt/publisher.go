@@ -18,12 +18,19 @@ package mqtt
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	paho "github.com/eclipse/paho.mqtt.golang"
 	"log"
-	"strings"
+	"time"
 )
 
+// connectTimeout limits how long connecting to a broker may take, so that unreachable brokers fail fast
+const connectTimeout = 10 * time.Second
+
+// publishTimeout limits how long a publish may wait for the broker, so that stalled connections fail the delivery
+const publishTimeout = 30 * time.Second
+
 type Publisher struct {
 	client paho.Client
 	qos    byte
@@ -31,16 +38,30 @@ type Publisher struct {
 }
 
 func NewPublisher(ctx context.Context, broker string, user string, pw string, client string, qos uint8, debug bool) (mqtt *Publisher, err error) {
-	if strings.Index(broker, ":") == -1 {
-		broker += ":1883"
+	return NewTLSPublisher(ctx, broker, user, pw, client, qos, TLSOptions{}, debug)
+}
+
+func NewTLSPublisher(ctx context.Context, broker string, user string, pw string, client string, qos uint8, tlsOptions TLSOptions, debug bool) (mqtt *Publisher, err error) {
+	broker, err = BrokerUrl(broker)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := tlsOptions.Config()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil && IsTLS(broker) {
+		tlsConfig = &tls.Config{}
 	}
 	mqtt = &Publisher{debug: debug, qos: qos}
 	options := paho.NewClientOptions().
+		SetTLSConfig(tlsConfig).
 		SetPassword(pw).
 		SetUsername(user).
 		SetAutoReconnect(true).
 		SetCleanSession(true).
 		SetClientID(client).
+		SetConnectTimeout(connectTimeout).
 		AddBroker(broker)
 
 	mqtt.client = paho.NewClient(options)
@@ -58,18 +79,27 @@ func NewPublisher(ctx context.Context, broker string, user string, pw string, cl
 }
 
 func (this *Publisher) Publish(topic string, msg string) (err error) {
+	return this.PublishWithRetain(topic, msg, false)
+}
+
+func (this *Publisher) PublishWithRetain(topic string, msg string, retain bool) (err error) {
 	if !this.client.IsConnected() {
 		return errors.New("mqtt client not connected")
 	}
 
-	token := this.client.Publish(topic, this.qos, false, msg)
+	token := this.client.Publish(topic, this.qos, retain, msg)
 	if this.debug {
 		log.Printf("Publish Mqtt on topic %v: %v", topic, msg)
 	}
-	if token.Wait() && token.Error() != nil {
-		return token.Error()
+	if !token.WaitTimeout(publishTimeout) {
+		return errors.New("mqtt publish timeout")
 	}
-	return err
+	return token.Error()
+}
+
+// PublishMessage publishes the message without its MQTT v5 properties
+func (this *Publisher) PublishMessage(msg Message) error {
+	return this.PublishWithRetain(msg.Topic, msg.Payload, msg.Retain)
 }
 
 func (this *Publisher) GetClient() paho.Client {
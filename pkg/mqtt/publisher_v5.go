@@ -0,0 +1,128 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/eclipse/paho.golang/autopaho"
+	"github.com/eclipse/paho.golang/paho"
+)
+
+// Message is published by PublishMessage. ContentType, UserProperties and MessageExpiry are MQTT v5 properties
+// and ignored by the v3.1.1 Publisher.
+type Message struct {
+	Topic          string
+	Payload        string
+	Retain         bool
+	ContentType    string
+	UserProperties []paho.UserProperty
+	MessageExpiry  uint32 // seconds, 0 for no expiry
+}
+
+const publishTimeoutV5 = 30 * time.Second
+
+// PublisherV5 publishes with MQTT v5 to brokers that opted in
+type PublisherV5 struct {
+	conn   *autopaho.ConnectionManager
+	cancel context.CancelFunc // closes the connection, also done when the ctx of NewPublisherV5 is done
+	qos    byte
+	debug  bool
+}
+
+func NewPublisherV5(ctx context.Context, broker string, user string, pw string, client string, qos uint8, tlsOptions TLSOptions, debug bool) (mqtt *PublisherV5, err error) {
+	broker, err = BrokerUrl(broker)
+	if err != nil {
+		return nil, err
+	}
+	serverUrl, err := url.Parse(broker)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := tlsOptions.Config()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil && IsTLS(broker) {
+		tlsConfig = &tls.Config{}
+	}
+	connectErr := make(chan error, 1)
+	connCtx, cancel := context.WithCancel(ctx) // the connection is closed when ctx is done
+	conn, err := autopaho.NewConnection(connCtx, autopaho.ClientConfig{
+		ServerUrls:                    []*url.URL{serverUrl},
+		TlsCfg:                        tlsConfig,
+		KeepAlive:                     30,
+		ConnectTimeout:                connectTimeout,
+		CleanStartOnInitialConnection: true,
+		ConnectUsername:               user,
+		ConnectPassword:               []byte(pw),
+		OnConnectError: func(err error) {
+			select {
+			case connectErr <- err:
+			default:
+			}
+		},
+		ClientConfig: paho.ClientConfig{ClientID: client},
+	})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	connected := make(chan error, 1)
+	go func() {
+		connected <- conn.AwaitConnection(connCtx)
+	}()
+	select {
+	case err = <-connected:
+	case err = <-connectErr: // autopaho retries forever, the first failure is reported like with the v3.1.1 publisher
+	}
+	if err != nil {
+		log.Println("Error on PublisherV5.Connect(): ", broker, user, client, err)
+		cancel()
+		return nil, err
+	}
+	log.Println("MQTT v5 publisher up and running...")
+	return &PublisherV5{conn: conn, cancel: cancel, qos: qos, debug: debug}, nil
+}
+
+func (this *PublisherV5) PublishMessage(msg Message) error {
+	properties := &paho.PublishProperties{
+		ContentType: msg.ContentType,
+		User:        msg.UserProperties,
+	}
+	if msg.MessageExpiry > 0 {
+		expiry := msg.MessageExpiry
+		properties.MessageExpiry = &expiry
+	}
+	if this.debug {
+		log.Printf("Publish Mqtt v5 on topic %v: %v", msg.Topic, msg.Payload)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), publishTimeoutV5)
+	defer cancel()
+	_, err := this.conn.Publish(ctx, &paho.Publish{
+		QoS:        this.qos,
+		Retain:     msg.Retain,
+		Topic:      msg.Topic,
+		Payload:    []byte(msg.Payload),
+		Properties: properties,
+	})
+	return err
+}
@@ -0,0 +1,77 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+type Subscriber struct {
+	client paho.Client
+}
+
+// NewSubscriber subscribes the handler to the topic. The subscription is renewed on reconnect.
+// Messages are handled concurrently and acknowledged after the handler returned.
+func NewSubscriber(ctx context.Context, broker string, user string, pw string, client string, qos uint8, tlsOptions TLSOptions, topic string, handler func(topic string, payload []byte), debug bool) (subscriber *Subscriber, err error) {
+	broker, err = BrokerUrl(broker)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig, err := tlsOptions.Config()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig == nil && IsTLS(broker) {
+		tlsConfig = &tls.Config{}
+	}
+	options := paho.NewClientOptions().
+		SetTLSConfig(tlsConfig).
+		SetPassword(pw).
+		SetUsername(user).
+		SetAutoReconnect(true).
+		SetCleanSession(true).
+		SetOrderMatters(false).
+		SetClientID(client).
+		AddBroker(broker).
+		SetOnConnectHandler(func(c paho.Client) {
+			token := c.Subscribe(topic, qos, func(_ paho.Client, message paho.Message) {
+				if debug {
+					log.Printf("Received Mqtt on topic %v: %v", message.Topic(), string(message.Payload()))
+				}
+				handler(message.Topic(), message.Payload())
+			})
+			if token.Wait() && token.Error() != nil {
+				log.Println("Error on Subscriber.Subscribe(): ", topic, token.Error())
+			}
+		})
+
+	subscriber = &Subscriber{client: paho.NewClient(options)}
+	if token := subscriber.client.Connect(); token.Wait() && token.Error() != nil {
+		log.Println("Error on Subscriber.Connect(): ", broker, user, client, token.Error())
+		return nil, token.Error()
+	}
+	log.Println("MQTT subscriber up and running...")
+	go func() {
+		<-ctx.Done()
+		subscriber.client.Disconnect(0)
+	}()
+	return subscriber, nil
+}
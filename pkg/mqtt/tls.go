@@ -0,0 +1,102 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqtt
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// TLSOptions configures the connection to brokers with ssl, tls, mqtts or wss urls.
+// Certificates and keys are PEM encoded.
+type TLSOptions struct {
+	CaCert     string // trusted in addition to the system roots
+	ClientCert string // optional, enables mutual tls together with ClientKey
+	ClientKey  string
+	Insecure   bool // skips verification of the broker certificate
+}
+
+var defaultPorts = map[string]string{
+	"tcp":   "1883",
+	"mqtt":  "1883",
+	"ssl":   "8883",
+	"tls":   "8883",
+	"mqtts": "8883",
+	"ws":    "80",
+	"wss":   "443",
+}
+
+// BrokerUrl returns the url used to connect to the broker address.
+// Addresses without scheme are handled as tcp, missing ports are replaced with the default port of the scheme.
+func BrokerUrl(address string) (string, error) {
+	if !strings.Contains(address, "://") {
+		address = "tcp://" + address
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return "", err
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	port, ok := defaultPorts[u.Scheme]
+	if !ok {
+		return "", fmt.Errorf("unsupported broker url scheme %s", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return "", errors.New("missing broker host")
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), port)
+	}
+	return u.String(), nil
+}
+
+// IsTLS reports if the broker url requires a tls connection
+func IsTLS(brokerUrl string) bool {
+	scheme, _, _ := strings.Cut(strings.ToLower(brokerUrl), "://")
+	return scheme == "ssl" || scheme == "tls" || scheme == "mqtts" || scheme == "wss"
+}
+
+// Config returns the tls config of the options, nil if they are empty
+func (this TLSOptions) Config() (*tls.Config, error) {
+	if this == (TLSOptions{}) {
+		return nil, nil
+	}
+	config := &tls.Config{InsecureSkipVerify: this.Insecure}
+	if this.CaCert != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM([]byte(this.CaCert)) {
+			return nil, errors.New("invalid ca certificate")
+		}
+		config.RootCAs = pool
+	}
+	if this.ClientCert != "" || this.ClientKey != "" {
+		cert, err := tls.X509KeyPair([]byte(this.ClientCert), []byte(this.ClientKey))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
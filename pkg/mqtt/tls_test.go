@@ -0,0 +1,111 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mqtt
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func TestBrokerUrl(t *testing.T) {
+	cases := map[string]string{
+		"localhost":                "tcp://localhost:1883",
+		"localhost:1884":           "tcp://localhost:1884",
+		"tcp://localhost":          "tcp://localhost:1883",
+		"ssl://localhost":          "ssl://localhost:8883",
+		"TLS://localhost:8884":     "tls://localhost:8884",
+		"mqtts://localhost":        "mqtts://localhost:8883",
+		"ws://localhost/mqtt":      "ws://localhost:80/mqtt",
+		"wss://localhost:9001/ws":  "wss://localhost:9001/ws",
+		"ssl://[::1]":              "ssl://[::1]:8883",
+		"tcp://user@localhost:123": "tcp://user@localhost:123",
+	}
+	for address, expected := range cases {
+		actual, err := BrokerUrl(address)
+		if err != nil {
+			t.Error(address, err)
+			continue
+		}
+		if actual != expected {
+			t.Error(address, actual, expected)
+		}
+	}
+	for _, address := range []string{"http://localhost", "ssl://", ""} {
+		_, err := BrokerUrl(address)
+		if err == nil {
+			t.Error("expected error for", address)
+		}
+	}
+}
+
+func TestTLSOptionsConfig(t *testing.T) {
+	config, err := TLSOptions{}.Config()
+	if err != nil || config != nil {
+		t.Error("expected no config for empty options", config, err)
+	}
+
+	certPem, keyPem := selfSignedCert(t)
+	config, err = TLSOptions{CaCert: certPem, ClientCert: certPem, ClientKey: keyPem, Insecure: true}.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.InsecureSkipVerify || config.RootCAs == nil || len(config.Certificates) != 1 {
+		t.Error("unexpected config", config)
+	}
+
+	_, err = TLSOptions{CaCert: "foo"}.Config()
+	if err == nil {
+		t.Error("expected error for invalid ca certificate")
+	}
+	_, err = TLSOptions{ClientCert: certPem}.Config()
+	if err == nil {
+		t.Error("expected error for missing client key")
+	}
+}
+
+func selfSignedCert(t *testing.T) (certPem string, keyPem string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPem = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	keyPem = string(pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+	return
+}
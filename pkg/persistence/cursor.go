@@ -0,0 +1,47 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package persistence
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Cursor points behind the last element of a page. It is handed to clients as an opaque string.
+type Cursor struct {
+	CreatedAt time.Time `json:"c"`
+	Id        string    `json:"i"`
+}
+
+func EncodeCursor(createdAt time.Time, id string) string {
+	b, _ := json.Marshal(Cursor{CreatedAt: createdAt, Id: id})
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeCursor(cursor string) (result Cursor, err error) {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return result, errors.New("invalid cursor")
+	}
+	err = json.Unmarshal(b, &result)
+	if err != nil || result.Id == "" {
+		return result, errors.New("invalid cursor")
+	}
+	return result, nil
+}
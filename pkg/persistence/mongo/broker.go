@@ -33,6 +33,12 @@ import (
 var brokerUserIdKey = "user_id"
 var brokerIdKey = "id"
 var brokerEnabledKey = "enabled"
+var brokerCreatedAtKey = "created_at"
+var brokerHealthLastSuccessAtKey = "health.last_success_at"
+var brokerHealthLastErrorAtKey = "health.last_error_at"
+var brokerHealthLastErrorKey = "health.last_error"
+var brokerHealthConsecutiveFailuresKey = "health.consecutive_failures"
+var brokerHealthDisabledAtKey = "health.disabled_at"
 
 func initBrokers() {
 	var err error
@@ -47,6 +53,10 @@ func initBrokers() {
 		if err != nil {
 			return err
 		}
+		err = db.ensureCompoundIndex(collection, "brokerusercreatedindex", true, false, brokerUserIdKey, brokerCreatedAtKey, brokerIdKey)
+		if err != nil {
+			return err
+		}
 		return nil
 	})
 }
@@ -59,21 +69,40 @@ func (this *Mongo) ListBrokers(userId string, o persistence.ListOptions) (result
 	result = []model.Broker{}
 	opt := options.Find()
 	opt.SetLimit(int64(o.Limit))
-	opt.SetSkip(int64(o.Offset))
 
 	filter := bson.M{}
 	if userId != "" {
 		filter[brokerUserIdKey] = userId
 	}
+	pageFilter := filter
+	if o.UseCursor {
+		var direction int32 = 1
+		if o.SortDesc {
+			direction = -1
+		}
+		opt.SetSort(bson.D{{Key: brokerCreatedAtKey, Value: direction}, {Key: brokerIdKey, Value: direction}})
+		if o.Cursor != "" {
+			c, err := persistence.DecodeCursor(o.Cursor)
+			if err != nil {
+				return result, total, err, http.StatusBadRequest
+			}
+			pageFilter = bson.M{"$and": []bson.M{filter, keysetFilter(brokerCreatedAtKey, brokerIdKey, c.CreatedAt, c.Id, o.SortDesc)}}
+		}
+	} else {
+		opt.SetSkip(int64(o.Offset))
+	}
 
 	ctx, _ := getTimeoutContext()
 	collection := this.brokerCollection()
 
-	total, err = collection.CountDocuments(ctx, filter)
-	if err != nil {
-		return result, total, err, http.StatusInternalServerError
+	total = -1
+	if !o.SkipTotal {
+		total, err = collection.CountDocuments(ctx, filter)
+		if err != nil {
+			return result, total, err, http.StatusInternalServerError
+		}
 	}
-	cursor, err := collection.Find(ctx, filter, opt)
+	cursor, err := collection.Find(ctx, pageFilter, opt)
 	if err != nil {
 		return result, total, err, http.StatusInternalServerError
 	}
@@ -218,6 +247,62 @@ func (this *Mongo) ListEnabledBrokers(userId string) (result []model.Broker, err
 	return
 }
 
+// SetBrokerDeliverySuccess resets the failures of the broker. to avoid a write per delivery,
+// last_success_at of healthy brokers is only updated if it is before staleBefore.
+func (this *Mongo) SetBrokerDeliverySuccess(id string, at time.Time, staleBefore time.Time) error {
+	ctx, _ := getTimeoutContext()
+	_, err := this.brokerCollection().UpdateOne(ctx, bson.M{
+		brokerIdKey: id,
+		"$or": []bson.M{
+			{brokerHealthConsecutiveFailuresKey: bson.M{"$ne": 0}},
+			{brokerHealthLastSuccessAtKey: bson.M{"$not": bson.M{"$gte": staleBefore}}},
+		},
+	}, bson.M{"$set": bson.M{
+		brokerHealthLastSuccessAtKey:       at,
+		brokerHealthConsecutiveFailuresKey: 0,
+	}})
+	return err
+}
+
+// SetBrokerDeliveryError stores the error of a failed delivery without counting it as consecutive failure
+func (this *Mongo) SetBrokerDeliveryError(id string, at time.Time, deliveryErr string) error {
+	ctx, _ := getTimeoutContext()
+	_, err := this.brokerCollection().UpdateOne(ctx, bson.M{brokerIdKey: id}, bson.M{"$set": bson.M{
+		brokerHealthLastErrorAtKey: at,
+		brokerHealthLastErrorKey:   deliveryErr,
+	}})
+	return err
+}
+
+// AddBrokerDeliveryFailure counts the failed delivery and disables the broker if disableAfter consecutive failures are reached.
+// disabled is only true for the call that disabled the broker.
+func (this *Mongo) AddBrokerDeliveryFailure(id string, at time.Time, deliveryErr string, disableAfter int64) (disabled bool, err error) {
+	ctx, _ := getTimeoutContext()
+	collection := this.brokerCollection()
+	result := model.Broker{}
+	err = collection.FindOneAndUpdate(ctx, bson.M{brokerIdKey: id}, bson.M{
+		"$inc": bson.M{brokerHealthConsecutiveFailuresKey: 1},
+		"$set": bson.M{brokerHealthLastErrorAtKey: at, brokerHealthLastErrorKey: deliveryErr},
+	}, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&result)
+	if err == mongo.ErrNoDocuments {
+		return false, nil // removed in the meantime
+	}
+	if err != nil {
+		return false, err
+	}
+	if disableAfter <= 0 || result.Health.ConsecutiveFailures < disableAfter {
+		return false, nil
+	}
+	update, err := collection.UpdateOne(ctx, bson.M{brokerIdKey: id, brokerEnabledKey: true}, bson.M{"$set": bson.M{
+		brokerEnabledKey:          false,
+		brokerHealthDisabledAtKey: at,
+	}})
+	if err != nil {
+		return false, err
+	}
+	return update.ModifiedCount > 0, nil
+}
+
 func (this *Mongo) HandlerBrokerMongoVaultConsistency(cleanupVaultKeys bool) (err error) {
 	start := time.Now()
 	vaultList, err := this.brokerManager.ListKeys()
@@ -0,0 +1,84 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mongo
+
+import (
+	"time"
+
+	"github.com/SENERGY-Platform/notifier/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var deferredDeliveryIdKey = "_id"
+var deferredDeliveryAtKey = "deliver_at"
+
+func initDeferredDeliveries() {
+	CreateCollections = append(CreateCollections, func(db *Mongo) error {
+		collection := db.client.Database(db.config.MongoTable).Collection(db.config.MongoDeferredDeliveryCollection)
+		return db.ensureIndex(collection, "deferreddeliveryatindex", deferredDeliveryAtKey, true, false)
+	})
+}
+
+func (this *Mongo) deferredDeliveryCollection() *mongo.Collection {
+	return this.client.Database(this.config.MongoTable).Collection(this.config.MongoDeferredDeliveryCollection)
+}
+
+// DeferDelivery stores the deferred delivery, replacing an existing one of the same notification and channel
+func (this *Mongo) DeferDelivery(delivery model.DeferredDelivery) error {
+	ctx, _ := getTimeoutContext()
+	_, err := this.deferredDeliveryCollection().ReplaceOne(
+		ctx,
+		bson.M{deferredDeliveryIdKey: delivery.Id},
+		delivery,
+		options.Replace().SetUpsert(true))
+	return err
+}
+
+// ClaimDueDeferredDeliveries removes and returns deferred deliveries that are due.
+// deleting each delivery before returning it ensures that only one instance handles it.
+func (this *Mongo) ClaimDueDeferredDeliveries(now time.Time, limit int64) (result []model.DeferredDelivery, err error) {
+	result = []model.DeferredDelivery{}
+	ctx, _ := getTimeoutContext()
+	cursor, err := this.deferredDeliveryCollection().Find(ctx,
+		bson.M{deferredDeliveryAtKey: bson.M{"$lte": now}},
+		options.Find().SetLimit(limit).SetSort(bson.D{{Key: deferredDeliveryAtKey, Value: 1}}))
+	if err != nil {
+		return result, err
+	}
+	due := []model.DeferredDelivery{}
+	err = cursor.All(ctx, &due)
+	if err != nil {
+		return result, err
+	}
+	for _, delivery := range due {
+		temp := this.deferredDeliveryCollection().FindOneAndDelete(ctx, bson.M{
+			deferredDeliveryIdKey: delivery.Id,
+			deferredDeliveryAtKey: delivery.DeliverAt,
+		})
+		err = temp.Err()
+		if err == mongo.ErrNoDocuments {
+			continue // claimed by another instance or deferred again
+		}
+		if err != nil {
+			return result, err
+		}
+		result = append(result, delivery)
+	}
+	return result, nil
+}
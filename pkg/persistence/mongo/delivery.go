@@ -0,0 +1,70 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mongo
+
+import (
+	"net/http"
+
+	"github.com/SENERGY-Platform/notifier/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var deliveryUserIdKey = "user_id"
+var deliveryNotificationIdKey = "notification_id"
+var deliveryTimestampKey = "timestamp"
+
+// delivery attempts are kept as long as finished outbox jobs
+const deliveryAttemptTTL = outboxFinishedTTL
+
+func initDeliveryAttempts() {
+	CreateCollections = append(CreateCollections, func(db *Mongo) error {
+		collection := db.client.Database(db.config.MongoTable).Collection(db.config.MongoDeliveryCollection)
+		err := db.ensureCompoundIndex(collection, "deliverynotificationtimestampindex", true, false, deliveryNotificationIdKey, deliveryTimestampKey)
+		if err != nil {
+			return err
+		}
+		return db.ensureTTLIndex(collection, "deliverytimestampindex", deliveryTimestampKey, deliveryAttemptTTL)
+	})
+}
+
+func (this *Mongo) deliveryCollection() *mongo.Collection {
+	return this.client.Database(this.config.MongoTable).Collection(this.config.MongoDeliveryCollection)
+}
+
+func (this *Mongo) AddDeliveryAttempt(attempt model.DeliveryAttempt) error {
+	ctx, _ := getTimeoutContext()
+	_, err := this.deliveryCollection().InsertOne(ctx, attempt)
+	return err
+}
+
+func (this *Mongo) ListDeliveryAttempts(userId string, notificationId string) (result []model.DeliveryAttempt, err error, errCode int) {
+	result = []model.DeliveryAttempt{}
+	ctx, _ := getTimeoutContext()
+	cursor, err := this.deliveryCollection().Find(ctx,
+		bson.M{deliveryUserIdKey: userId, deliveryNotificationIdKey: notificationId},
+		options.Find().SetSort(bson.D{{Key: deliveryTimestampKey, Value: 1}, {Key: "_id", Value: 1}}))
+	if err != nil {
+		return result, err, http.StatusInternalServerError
+	}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return result, err, http.StatusInternalServerError
+	}
+	return result, nil, http.StatusOK
+}
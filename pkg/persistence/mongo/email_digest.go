@@ -0,0 +1,167 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mongo
+
+import (
+	"time"
+
+	"github.com/SENERGY-Platform/notifier/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var emailDigestUserIdKey = "user_id"
+var emailDigestNotificationIdKey = "notification_id"
+var emailDigestDueAtKey = "due_at"
+var emailDigestPendingKey = "pending"
+var emailDigestIdKey = "digest_id"
+var emailDigestSentAtKey = "sent_at"
+var emailDigestClaimedAtKey = "claimed_at"
+var emailDigestAttemptsKey = "attempts"
+
+// records of sent digests are kept for a week
+const emailDigestRecordTTL = 7 * 24 * time.Hour
+
+func initEmailDigests() {
+	CreateCollections = append(CreateCollections, func(db *Mongo) error {
+		collection := db.client.Database(db.config.MongoTable).Collection(db.config.MongoEmailDigestCollection)
+		err := db.ensureCompoundIndex(collection, "emaildigestpendingdueindex", true, false, emailDigestPendingKey, emailDigestDueAtKey)
+		if err != nil {
+			return err
+		}
+		err = db.ensureIndex(collection, "emaildigestidindex", emailDigestIdKey, true, false)
+		if err != nil {
+			return err
+		}
+		err = db.ensurePartialUniqueIndex(collection, "emaildigestpendingnotificationindex", emailDigestPendingKey, emailDigestNotificationIdKey)
+		if err != nil {
+			return err
+		}
+		return db.ensureTTLIndex(collection, "emaildigestsentatindex", emailDigestSentAtKey, emailDigestRecordTTL)
+	})
+}
+
+func (this *Mongo) emailDigestCollection() *mongo.Collection {
+	return this.client.Database(this.config.MongoTable).Collection(this.config.MongoEmailDigestCollection)
+}
+
+// QueueEmailDigestEntry adds the notification to the next digest, if it is not already waiting for one
+func (this *Mongo) QueueEmailDigestEntry(entry model.EmailDigestEntry) error {
+	ctx, _ := getTimeoutContext()
+	entry.Pending = true
+	if entry.Id == "" {
+		entry.Id = primitive.NewObjectID().Hex()
+	}
+	_, err := this.emailDigestCollection().UpdateOne(
+		ctx,
+		bson.M{emailDigestNotificationIdKey: entry.NotificationId, emailDigestPendingKey: true},
+		bson.M{"$setOnInsert": entry},
+		options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return nil // queued concurrently
+	}
+	return err
+}
+
+// ClaimDueEmailDigests collects the due entries of up to limit users into digests.
+// the entries are claimed for the lease, so that no other instance sends them meanwhile.
+// claims older than the lease belong to instances that stopped while sending and are claimed again.
+func (this *Mongo) ClaimDueEmailDigests(now time.Time, lease time.Duration, limit int64) (result []model.EmailDigest, err error) {
+	result = []model.EmailDigest{}
+	ctx, _ := getTimeoutContext()
+	claimable := bson.M{
+		emailDigestPendingKey: true,
+		emailDigestDueAtKey:   bson.M{"$lte": now},
+		"$or": []bson.M{
+			{emailDigestClaimedAtKey: bson.M{"$exists": false}},
+			{emailDigestClaimedAtKey: bson.M{"$lte": now.Add(-lease)}},
+		},
+	}
+	cursor, err := this.emailDigestCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: claimable}},
+		{{Key: "$group", Value: bson.M{"_id": "$" + emailDigestUserIdKey}}},
+		{{Key: "$limit", Value: limit}},
+	})
+	if err != nil {
+		return result, err
+	}
+	users := []struct {
+		UserId string `bson:"_id"`
+	}{}
+	err = cursor.All(ctx, &users)
+	if err != nil {
+		return result, err
+	}
+	for _, user := range users {
+		digestId := primitive.NewObjectID().Hex()
+		filter := bson.M{emailDigestUserIdKey: user.UserId}
+		for key, value := range claimable {
+			filter[key] = value
+		}
+		_, err = this.emailDigestCollection().UpdateMany(ctx, filter,
+			bson.M{"$set": bson.M{emailDigestIdKey: digestId, emailDigestClaimedAtKey: now}})
+		if err != nil {
+			return result, err
+		}
+		entries := []model.EmailDigestEntry{}
+		entryCursor, err := this.emailDigestCollection().Find(ctx, bson.M{emailDigestIdKey: digestId, emailDigestPendingKey: true})
+		if err != nil {
+			return result, err
+		}
+		err = entryCursor.All(ctx, &entries)
+		if err != nil {
+			return result, err
+		}
+		if len(entries) == 0 {
+			continue // claimed by another instance
+		}
+		digest := model.EmailDigest{Id: digestId, UserId: user.UserId}
+		for _, entry := range entries {
+			digest.NotificationIds = append(digest.NotificationIds, entry.NotificationId)
+			digest.Attempts = max(digest.Attempts, entry.Attempts)
+		}
+		result = append(result, digest)
+	}
+	return result, nil
+}
+
+// CompleteEmailDigest marks the claimed entries of the digest as sent
+func (this *Mongo) CompleteEmailDigest(digestId string, sentAt time.Time) error {
+	ctx, _ := getTimeoutContext()
+	_, err := this.emailDigestCollection().UpdateMany(ctx,
+		bson.M{emailDigestIdKey: digestId, emailDigestPendingKey: true},
+		bson.M{
+			"$set":   bson.M{emailDigestSentAtKey: sentAt},
+			"$unset": bson.M{emailDigestPendingKey: "", emailDigestClaimedAtKey: ""},
+		})
+	return err
+}
+
+// ReleaseEmailDigest returns the entries of a digest that could not be sent to the queue, due at retryAt
+func (this *Mongo) ReleaseEmailDigest(digestId string, retryAt time.Time) error {
+	ctx, _ := getTimeoutContext()
+	_, err := this.emailDigestCollection().UpdateMany(ctx,
+		bson.M{emailDigestIdKey: digestId, emailDigestPendingKey: true},
+		bson.M{
+			"$set":   bson.M{emailDigestDueAtKey: retryAt},
+			"$unset": bson.M{emailDigestIdKey: "", emailDigestClaimedAtKey: ""},
+			"$inc":   bson.M{emailDigestAttemptsKey: 1},
+		})
+	return err
+}
@@ -63,6 +63,10 @@ func New(conf configuration.Config) (*Mongo, error) {
 	initBrokers()
 	initPlatformBrokers()
 	initSettings()
+	initDeferredDeliveries()
+	initEmailDigests()
+	initOutbox()
+	initDeliveryAttempts()
 	for _, creators := range CreateCollections {
 		err = creators(db)
 		if err != nil {
@@ -117,6 +121,29 @@ func (this *Mongo) ensureCompoundIndex(collection *mongo.Collection, indexname s
 	return err
 }
 
+func (this *Mongo) ensureTTLIndex(collection *mongo.Collection, indexname string, indexKey string, expireAfter time.Duration) error {
+	ctx, _ := getTimeoutContext()
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: indexKey, Value: 1}},
+		Options: options.Index().SetName(indexname).SetExpireAfterSeconds(int32(expireAfter.Seconds())),
+	})
+	return err
+}
+
+// ensurePartialUniqueIndex creates a unique index that only covers documents where the partialKey field exists
+func (this *Mongo) ensurePartialUniqueIndex(collection *mongo.Collection, indexname string, partialKey string, indexKeys ...string) error {
+	ctx, _ := getTimeoutContext()
+	keys := bson.D{}
+	for _, key := range indexKeys {
+		keys = append(keys, bson.E{Key: key, Value: 1})
+	}
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetName(indexname).SetUnique(true).SetPartialFilterExpression(bson.M{partialKey: bson.M{"$exists": true}}),
+	})
+	return err
+}
+
 func (this *Mongo) ensureTextIndex(collection *mongo.Collection, indexname string, indexKeys ...string) error {
 	if len(indexKeys) == 0 {
 		return errors.New("expect at least one key")
@@ -191,6 +218,18 @@ func getBsonFieldPath(obj interface{}, path string) (bsonPath string, err error)
 	return
 }
 
+// keysetFilter matches all documents sorted behind the cursor position of a sort on createdAtKey and idKey
+func keysetFilter(createdAtKey string, idKey string, createdAt time.Time, id interface{}, desc bool) bson.M {
+	op := "$gt"
+	if desc {
+		op = "$lt"
+	}
+	return bson.M{"$or": []bson.M{
+		{createdAtKey: bson.M{op: createdAt}},
+		{createdAtKey: createdAt, idKey: bson.M{op: id}},
+	}}
+}
+
 func getTimeoutContext() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), 10*time.Second)
 }
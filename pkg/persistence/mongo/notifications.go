@@ -20,6 +20,7 @@ import (
 	"errors"
 	"log"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/SENERGY-Platform/notifier/pkg/model"
@@ -31,14 +32,33 @@ import (
 )
 
 const notificationTitleFieldName = "Title"
+const notificationMessageFieldName = "Message"
 const deviceCreatedAtFieldName = "CreatedAt"
 
 var notificationTitleKey string
+var notificationMessageKey string
 var notificationUserIdKey = "userId"
 var notificationCreatedAtKey string
 var notificationIdKey = "_id"
 var notificationHashKey = "hash"
+var notificationIsReadKey = "isRead"
 var topicKey = "topic"
+var notificationScoreKey = "score"
+var notificationExpiresAtKey = "expires_at"
+var notificationDeliverAtKey = "deliver_at"
+var notificationClaimedAtKey = "claimed_at"
+var notificationMetadataKey = "metadata"
+var notificationCorrelationKeyKey = "correlation_key"
+var notificationOccurrencesKey = "occurrences"
+var notificationResolvedAtKey = "resolved_at"
+var notificationLastOccurredAtKey = "last_occurred_at"
+
+// optional fields are omitted when empty and need to be removed explicitly if a correlated notification replaces an existing one
+var notificationOptionalKeys = []string{notificationExpiresAtKey, "link", "actions", notificationMetadataKey, notificationResolvedAtKey, notificationLastOccurredAtKey}
+
+// expired notifications are removed by the controller, which informs clients about the deletion.
+// the ttl index only serves as fallback if the cleanup is disabled or lagging behind.
+const notificationTTLGracePeriod = 24 * time.Hour
 
 func initNotifications() {
 	var err error
@@ -47,6 +67,11 @@ func initNotifications() {
 		log.Fatal(err)
 	}
 
+	notificationMessageKey, err = getBsonFieldPath(model.Notification{}, notificationMessageFieldName)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	notificationCreatedAtKey, err = getBsonFieldName(model.Notification{}, deviceCreatedAtFieldName)
 	if err != nil {
 		log.Fatal(err)
@@ -66,6 +91,38 @@ func initNotifications() {
 		if err != nil {
 			return err
 		}
+		err = db.ensureCompoundIndex(collection, "notificationusercreatedindex", true, false, notificationUserIdKey, notificationCreatedAtKey)
+		if err != nil {
+			return err
+		}
+		err = db.ensureCompoundIndex(collection, "notificationuserreadcreatedindex", true, false, notificationUserIdKey, notificationIsReadKey, notificationCreatedAtKey)
+		if err != nil {
+			return err
+		}
+		err = db.ensureCompoundIndex(collection, "notificationusertopiccreatedindex", true, false, notificationUserIdKey, topicKey, notificationCreatedAtKey)
+		if err != nil {
+			return err
+		}
+		err = db.ensureCompoundIndex(collection, "notificationusertitleindex", true, false, notificationUserIdKey, notificationTitleKey)
+		if err != nil {
+			return err
+		}
+		err = db.ensureTextIndex(collection, "notificationtextindex", notificationTitleKey, notificationMessageKey)
+		if err != nil {
+			return err
+		}
+		err = db.ensureTTLIndex(collection, "notificationexpiresatindex", notificationExpiresAtKey, notificationTTLGracePeriod)
+		if err != nil {
+			return err
+		}
+		err = db.ensureIndex(collection, "notificationdeliveratindex", notificationDeliverAtKey, true, false)
+		if err != nil {
+			return err
+		}
+		err = db.ensurePartialUniqueIndex(collection, "notificationusercorrelationindex", notificationCorrelationKeyKey, notificationUserIdKey, notificationCorrelationKeyKey)
+		if err != nil {
+			return err
+		}
 		return nil
 	})
 }
@@ -78,18 +135,43 @@ func (this *Mongo) ListNotifications(userId string, o persistence.ListOptions, t
 	result = []model.Notification{}
 	opt := options.Find()
 	opt.SetLimit(int64(o.Limit))
-	opt.SetSkip(int64(o.Offset))
+	opt.SetSort(notificationListSort(o))
+	if o.Search != "" {
+		opt.SetProjection(bson.M{notificationScoreKey: bson.M{"$meta": "textScore"}})
+	}
 
-	filter := bson.M{notificationUserIdKey: userId, topicKey: bson.M{"$in": topics}}
+	filter := notificationListFilter(userId, o, topics)
+	pageFilter := filter
+	if o.UseCursor {
+		if o.SortBy == persistence.SortByTitle {
+			return result, total, errors.New("cursor paging is only supported when sorting by created_at"), http.StatusBadRequest
+		}
+		if o.Cursor != "" {
+			c, err := persistence.DecodeCursor(o.Cursor)
+			if err != nil {
+				return result, total, err, http.StatusBadRequest
+			}
+			id, err := primitive.ObjectIDFromHex(c.Id)
+			if err != nil {
+				return result, total, err, http.StatusBadRequest
+			}
+			pageFilter = bson.M{"$and": []bson.M{filter, keysetFilter(notificationCreatedAtKey, notificationIdKey, c.CreatedAt, id, o.SortDesc)}}
+		}
+	} else {
+		opt.SetSkip(int64(o.Offset))
+	}
 
 	ctx, _ := getTimeoutContext()
 	collection := this.notificationCollection()
 
-	total, err = collection.CountDocuments(ctx, filter)
-	if err != nil {
-		return result, total, err, http.StatusInternalServerError
+	total = -1
+	if !o.SkipTotal {
+		total, err = collection.CountDocuments(ctx, filter)
+		if err != nil {
+			return result, total, err, http.StatusInternalServerError
+		}
 	}
-	cursor, err := collection.Find(ctx, filter, opt)
+	cursor, err := collection.Find(ctx, pageFilter, opt)
 	if err != nil {
 		return result, total, err, http.StatusInternalServerError
 	}
@@ -105,6 +187,55 @@ func (this *Mongo) ListNotifications(userId string, o persistence.ListOptions, t
 	return
 }
 
+func notificationListFilter(userId string, o persistence.ListOptions, topics []model.Topic) bson.M {
+	if len(o.Topics) > 0 {
+		requested := []model.Topic{}
+		for _, topic := range o.Topics {
+			if slices.Contains(topics, topic) {
+				requested = append(requested, topic)
+			}
+		}
+		topics = requested
+	}
+	filter := bson.M{notificationUserIdKey: userId, topicKey: bson.M{"$in": topics}, notificationDeliverAtKey: bson.M{"$exists": false}, notificationExpiresAtKey: notExpired()}
+	if o.Search != "" {
+		filter["$text"] = bson.M{"$search": o.Search}
+	}
+	if o.IsRead != nil {
+		filter[notificationIsReadKey] = *o.IsRead
+	}
+	for key, value := range o.Labels {
+		filter[notificationMetadataKey+"."+key] = value
+	}
+	createdAt := bson.M{}
+	if o.CreatedAfter != nil {
+		createdAt["$gte"] = *o.CreatedAfter
+	}
+	if o.CreatedBefore != nil {
+		createdAt["$lt"] = *o.CreatedBefore
+	}
+	if len(createdAt) > 0 {
+		filter[notificationCreatedAtKey] = createdAt
+	}
+	return filter
+}
+
+func notificationListSort(o persistence.ListOptions) bson.D {
+	if o.Search != "" && o.SortBy == "" && !o.UseCursor {
+		return bson.D{{Key: notificationScoreKey, Value: bson.M{"$meta": "textScore"}}, {Key: notificationCreatedAtKey, Value: -1}, {Key: notificationIdKey, Value: -1}}
+	}
+	var direction int32 = 1
+	if o.SortDesc {
+		direction = -1
+	}
+	sortKey := notificationCreatedAtKey
+	if o.SortBy == persistence.SortByTitle {
+		sortKey = notificationTitleKey
+	}
+	// _id as tie-breaker keeps pages stable for equal sort values
+	return bson.D{{Key: sortKey, Value: direction}, {Key: notificationIdKey, Value: direction}}
+}
+
 func (this *Mongo) ReadNotification(userId string, id string) (result model.Notification, err error, errCode int) {
 	objectId, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
@@ -117,6 +248,7 @@ func (this *Mongo) ReadNotification(userId string, id string) (result model.Noti
 			"$and": []bson.M{
 				{notificationIdKey: objectId},
 				{notificationUserIdKey: userId},
+				{notificationExpiresAtKey: notExpired()},
 			},
 		})
 	err = temp.Err()
@@ -145,6 +277,8 @@ func (this *Mongo) ReadNotificationByHash(userId string, hash [32]byte, notOlder
 				{notificationHashKey: hash},
 				{notificationUserIdKey: userId},
 				{notificationCreatedAtKey: bson.M{"$gte": notOlderThan}},
+				{notificationDeliverAtKey: bson.M{"$exists": false}}, // scheduled notifications are not delivered yet
+				{notificationExpiresAtKey: notExpired()},
 			},
 		}, &options.FindOneOptions{Sort: bson.D{{"created_at", -1}}})
 	err = temp.Err()
@@ -177,12 +311,217 @@ func (this *Mongo) SetNotification(notification model.Notification) (error, int)
 		},
 		notificationDb,
 		options.Replace().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return errors.New("correlation_key is already used by another notification"), http.StatusConflict
+	}
 	if err != nil {
 		return err, http.StatusInternalServerError
 	}
 	return nil, http.StatusOK
 }
 
+// UpsertNotificationByCorrelationKey replaces the content of the users notification with the same correlation key
+// and increments its occurrences, or inserts the notification if none exists.
+func (this *Mongo) UpsertNotificationByCorrelationKey(notification model.Notification) (result model.Notification, err error, errCode int) {
+	notificationDb, err := notification.ToDB()
+	if err != nil {
+		return result, err, http.StatusInternalServerError // Id set by app
+	}
+	raw, err := bson.Marshal(notificationDb)
+	if err != nil {
+		return result, err, http.StatusInternalServerError
+	}
+	set := bson.M{}
+	err = bson.Unmarshal(raw, &set)
+	if err != nil {
+		return result, err, http.StatusInternalServerError
+	}
+	delete(set, notificationIdKey)
+	delete(set, notificationOccurrencesKey)
+	update := bson.M{
+		"$set":         set,
+		"$inc":         bson.M{notificationOccurrencesKey: 1},
+		"$setOnInsert": bson.M{notificationIdKey: notificationDb.Id},
+	}
+	unset := bson.M{}
+	for _, key := range notificationOptionalKeys {
+		if _, ok := set[key]; !ok {
+			unset[key] = ""
+		}
+	}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+	filter := bson.M{
+		notificationUserIdKey:         notification.UserId,
+		notificationCorrelationKeyKey: notification.CorrelationKey,
+	}
+	opt := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	for attempt := 0; attempt < 2; attempt++ {
+		ctx, _ := getTimeoutContext()
+		temp := this.notificationCollection().FindOneAndUpdate(ctx, filter, update, opt)
+		err = temp.Err()
+		if mongo.IsDuplicateKeyError(err) {
+			continue // concurrent insert with the same key, the retry updates the inserted notification
+		}
+		if err != nil {
+			return result, err, http.StatusInternalServerError
+		}
+		err = temp.Decode(&result)
+		if err != nil {
+			return result, err, http.StatusInternalServerError
+		}
+		return result, nil, http.StatusOK
+	}
+	return result, err, http.StatusConflict
+}
+
+// ClaimDueNotifications returns notifications whose scheduled delivery time has passed.
+// each notification is leased by setting claimed_at, which ensures that only one instance delivers it.
+// deliver_at is kept until CompleteScheduledNotification, notifications not completed within the lease are claimed again.
+func (this *Mongo) ClaimDueNotifications(now time.Time, lease time.Duration, limit int64) (result []model.Notification, err error) {
+	result = []model.Notification{}
+	ctx, _ := getTimeoutContext()
+	claimable := bson.M{
+		notificationDeliverAtKey: bson.M{"$lte": now},
+		"$or": []bson.M{
+			{notificationClaimedAtKey: bson.M{"$exists": false}},
+			{notificationClaimedAtKey: bson.M{"$lte": now.Add(-lease)}},
+		},
+	}
+	cursor, err := this.notificationCollection().Find(ctx, claimable,
+		options.Find().SetLimit(limit).SetSort(bson.D{{Key: notificationDeliverAtKey, Value: 1}}).SetProjection(bson.M{notificationIdKey: 1}))
+	if err != nil {
+		return result, err
+	}
+	ids := []struct {
+		Id primitive.ObjectID `bson:"_id"`
+	}{}
+	err = cursor.All(ctx, &ids)
+	if err != nil {
+		return result, err
+	}
+	for _, id := range ids {
+		filter := bson.M{notificationIdKey: id.Id}
+		for key, value := range claimable {
+			filter[key] = value
+		}
+		temp := this.notificationCollection().FindOneAndUpdate(ctx, filter,
+			bson.M{"$set": bson.M{notificationClaimedAtKey: now}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After))
+		err = temp.Err()
+		if err == mongo.ErrNoDocuments {
+			continue // claimed by another instance
+		}
+		if err != nil {
+			return result, err
+		}
+		notification := model.Notification{}
+		err = temp.Decode(&notification)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, notification)
+	}
+	return result, nil
+}
+
+// CompleteScheduledNotification removes deliver_at of the delivered notification, which makes it visible in lists.
+// created_at is set to the scheduled time.
+func (this *Mongo) CompleteScheduledNotification(id string) error {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return err
+	}
+	ctx, _ := getTimeoutContext()
+	_, err = this.notificationCollection().UpdateOne(ctx,
+		bson.M{notificationIdKey: objectId, notificationDeliverAtKey: bson.M{"$exists": true}},
+		mongo.Pipeline{
+			{{Key: "$set", Value: bson.M{notificationCreatedAtKey: "$" + notificationDeliverAtKey}}},
+			{{Key: "$unset", Value: bson.A{notificationDeliverAtKey, notificationClaimedAtKey}}},
+		})
+	return err
+}
+
+// IncrementNotificationOccurrences counts a suppressed duplicate of the notification.
+// Notifications created before occurrences were tracked count as one previous occurrence.
+func (this *Mongo) IncrementNotificationOccurrences(userId string, id string, occurredAt time.Time) (result model.Notification, err error, errCode int) {
+	objectId, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return result, err, http.StatusInternalServerError // Id read from db
+	}
+	ctx, _ := getTimeoutContext()
+	temp := this.notificationCollection().FindOneAndUpdate(
+		ctx,
+		bson.M{
+			notificationIdKey:     objectId,
+			notificationUserIdKey: userId,
+		},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			notificationOccurrencesKey:    bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$" + notificationOccurrencesKey, 1}}, 1}},
+			notificationLastOccurredAtKey: occurredAt,
+		}}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After))
+	err = temp.Err()
+	if err == mongo.ErrNoDocuments {
+		return result, err, http.StatusNotFound
+	}
+	if err != nil {
+		return result, err, http.StatusInternalServerError
+	}
+	err = temp.Decode(&result)
+	if err != nil {
+		return result, err, http.StatusInternalServerError
+	}
+	return result, nil, http.StatusOK
+}
+
+func (this *Mongo) ResolveNotification(userId string, correlationKey string, resolvedAt time.Time) (result model.Notification, err error, errCode int) {
+	ctx, _ := getTimeoutContext()
+	temp := this.notificationCollection().FindOneAndUpdate(
+		ctx,
+		bson.M{
+			notificationUserIdKey:         userId,
+			notificationCorrelationKeyKey: correlationKey,
+		},
+		bson.M{"$set": bson.M{notificationResolvedAtKey: resolvedAt}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After))
+	err = temp.Err()
+	if err == mongo.ErrNoDocuments {
+		return result, err, http.StatusNotFound
+	}
+	if err != nil {
+		return result, err, http.StatusInternalServerError
+	}
+	err = temp.Decode(&result)
+	if err != nil {
+		return result, err, http.StatusInternalServerError
+	}
+	return result, nil, http.StatusOK
+}
+
+func (this *Mongo) RemoveNotificationByCorrelationKey(userId string, correlationKey string) (result model.Notification, err error, errCode int) {
+	ctx, _ := getTimeoutContext()
+	temp := this.notificationCollection().FindOneAndDelete(
+		ctx,
+		bson.M{
+			notificationUserIdKey:         userId,
+			notificationCorrelationKeyKey: correlationKey,
+		})
+	err = temp.Err()
+	if err == mongo.ErrNoDocuments {
+		return result, err, http.StatusNotFound
+	}
+	if err != nil {
+		return result, err, http.StatusInternalServerError
+	}
+	err = temp.Decode(&result)
+	if err != nil {
+		return result, err, http.StatusInternalServerError
+	}
+	return result, nil, http.StatusOK
+}
+
 func (this *Mongo) RemoveNotifications(userId string, ids []string) (error, int) {
 	objectIds := make([]primitive.ObjectID, len(ids))
 
@@ -244,3 +583,142 @@ func (this *Mongo) migrateHash() error {
 	}
 	return err
 }
+
+func (this *Mongo) CountNotifications(userId string, topics []model.Topic) (result model.NotificationCounts, err error, errCode int) {
+	result.Topics = map[model.Topic]model.NotificationCount{}
+	ctx, _ := getTimeoutContext()
+	cursor, err := this.notificationCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{notificationUserIdKey: userId, topicKey: bson.M{"$in": topics}, notificationDeliverAtKey: bson.M{"$exists": false}, notificationExpiresAtKey: notExpired()}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":    "$" + topicKey,
+			"total":  bson.M{"$sum": 1},
+			"unread": bson.M{"$sum": bson.M{"$cond": bson.A{"$" + notificationIsReadKey, 0, 1}}},
+		}}},
+	})
+	if err != nil {
+		return result, err, http.StatusInternalServerError
+	}
+	for cursor.Next(ctx) {
+		element := struct {
+			Topic  model.Topic `bson:"_id"`
+			Total  int64       `bson:"total"`
+			Unread int64       `bson:"unread"`
+		}{}
+		err = cursor.Decode(&element)
+		if err != nil {
+			return result, err, http.StatusInternalServerError
+		}
+		result.Topics[element.Topic] = model.NotificationCount{Total: element.Total, Unread: element.Unread}
+		result.Total += element.Total
+		result.Unread += element.Unread
+	}
+	err = cursor.Err()
+	if err != nil {
+		return result, err, http.StatusInternalServerError
+	}
+	return result, nil, http.StatusOK
+}
+
+func (this *Mongo) SetNotificationsReadState(userId string, ids []string, o persistence.ListOptions, topics []model.Topic, isRead bool) (updatedIds []string, err error, errCode int) {
+	updatedIds = []string{}
+	var filter bson.M
+	if len(ids) > 0 {
+		objectIds := make([]primitive.ObjectID, len(ids))
+		for i := range ids {
+			objectIds[i], err = primitive.ObjectIDFromHex(ids[i])
+			if err != nil {
+				return updatedIds, err, http.StatusBadRequest // ids requested by user
+			}
+		}
+		filter = bson.M{notificationUserIdKey: userId, notificationIdKey: bson.M{"$in": objectIds}, notificationExpiresAtKey: notExpired()}
+	} else {
+		filter = notificationListFilter(userId, o, topics)
+	}
+	filter[notificationIsReadKey] = bson.M{"$ne": isRead}
+
+	ctx, _ := getTimeoutContext()
+	collection := this.notificationCollection()
+
+	// collect ids first to be able to inform clients about the affected notifications
+	cursor, err := collection.Find(ctx, filter, options.Find().SetProjection(bson.M{notificationIdKey: 1}))
+	if err != nil {
+		return updatedIds, err, http.StatusInternalServerError
+	}
+	objectIds := []primitive.ObjectID{}
+	for cursor.Next(ctx) {
+		element := struct {
+			Id primitive.ObjectID `bson:"_id"`
+		}{}
+		err = cursor.Decode(&element)
+		if err != nil {
+			return updatedIds, err, http.StatusInternalServerError
+		}
+		objectIds = append(objectIds, element.Id)
+		updatedIds = append(updatedIds, element.Id.Hex())
+	}
+	err = cursor.Err()
+	if err != nil {
+		return updatedIds, err, http.StatusInternalServerError
+	}
+	if len(objectIds) == 0 {
+		return updatedIds, nil, http.StatusOK
+	}
+	_, err = collection.UpdateMany(ctx, bson.M{
+		notificationUserIdKey: userId,
+		notificationIdKey:     bson.M{"$in": objectIds},
+	}, bson.M{"$set": bson.M{notificationIsReadKey: isRead}})
+	if err != nil {
+		return updatedIds, err, http.StatusInternalServerError
+	}
+	return updatedIds, nil, http.StatusOK
+}
+
+// notExpired matches notifications without expires_at or expiring in the future.
+// expired notifications are removed by the cleanup job, until then they are hidden.
+func notExpired() bson.M {
+	return bson.M{"$not": bson.M{"$lte": time.Now()}}
+}
+
+// RemoveExpiredNotifications removes up to limit notifications with expires_at before now and returns their ids grouped by user id
+func (this *Mongo) RemoveExpiredNotifications(now time.Time, limit int64) (removed map[string][]string, err error) {
+	return this.removeNotificationsMatching(bson.M{notificationExpiresAtKey: bson.M{"$lte": now}}, limit)
+}
+
+// RemoveNotificationsOlderThan removes up to limit notifications of the user and topic created before olderThan and returns their ids
+func (this *Mongo) RemoveNotificationsOlderThan(userId string, topic model.Topic, olderThan time.Time, limit int64) (removed []string, err error) {
+	removedByUser, err := this.removeNotificationsMatching(bson.M{
+		notificationUserIdKey:    userId,
+		topicKey:                 topic,
+		notificationCreatedAtKey: bson.M{"$lt": olderThan},
+	}, limit)
+	return removedByUser[userId], err
+}
+
+func (this *Mongo) removeNotificationsMatching(filter bson.M, limit int64) (removed map[string][]string, err error) {
+	removed = map[string][]string{}
+	ctx, _ := getTimeoutContext()
+	collection := this.notificationCollection()
+	cursor, err := collection.Find(ctx, filter, options.Find().SetLimit(limit).SetProjection(bson.M{notificationIdKey: 1, notificationUserIdKey: 1}))
+	if err != nil {
+		return removed, err
+	}
+	objectIds := []primitive.ObjectID{}
+	for cursor.Next(ctx) {
+		element := struct {
+			Id     primitive.ObjectID `bson:"_id"`
+			UserId string             `bson:"userId"`
+		}{}
+		err = cursor.Decode(&element)
+		if err != nil {
+			return removed, err
+		}
+		objectIds = append(objectIds, element.Id)
+		removed[element.UserId] = append(removed[element.UserId], element.Id.Hex())
+	}
+	err = cursor.Err()
+	if err != nil || len(objectIds) == 0 {
+		return removed, err
+	}
+	_, err = collection.DeleteMany(ctx, bson.M{notificationIdKey: bson.M{"$in": objectIds}})
+	return removed, err
+}
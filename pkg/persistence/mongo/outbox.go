@@ -0,0 +1,111 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package mongo
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/SENERGY-Platform/notifier/pkg/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var outboxIdKey = "_id"
+var outboxUserIdKey = "user_id"
+var outboxCreatedAtKey = "created_at"
+var outboxStateKey = "state"
+var outboxNextAttemptAtKey = "next_attempt_at"
+var outboxAttemptsKey = "attempts"
+var outboxNotificationIdKey = "notification_id"
+var outboxFinishedAtKey = "finished_at"
+
+// finished jobs are kept for a week to inspect deliveries
+const outboxFinishedTTL = 7 * 24 * time.Hour
+
+func initOutbox() {
+	CreateCollections = append(CreateCollections, func(db *Mongo) error {
+		collection := db.client.Database(db.config.MongoTable).Collection(db.config.MongoOutboxCollection)
+		err := db.ensureCompoundIndex(collection, "outboxstatenextattemptindex", true, false, outboxStateKey, outboxNextAttemptAtKey)
+		if err != nil {
+			return err
+		}
+		err = db.ensureIndex(collection, "outboxnotificationindex", outboxNotificationIdKey, true, false)
+		if err != nil {
+			return err
+		}
+		return db.ensureTTLIndex(collection, "outboxfinishedatindex", outboxFinishedAtKey, outboxFinishedTTL)
+	})
+}
+
+func (this *Mongo) outboxCollection() *mongo.Collection {
+	return this.client.Database(this.config.MongoTable).Collection(this.config.MongoOutboxCollection)
+}
+
+func (this *Mongo) EnqueueOutboxJob(job model.OutboxJob) error {
+	ctx, _ := getTimeoutContext()
+	_, err := this.outboxCollection().InsertOne(ctx, job)
+	return err
+}
+
+// ClaimOutboxJob returns the pending job with the oldest due attempt and counts the attempt.
+// the job is leased by moving its next attempt behind the lease, so that it is resumed if the worker dies.
+func (this *Mongo) ClaimOutboxJob(now time.Time, lease time.Duration) (job model.OutboxJob, found bool, err error) {
+	ctx, _ := getTimeoutContext()
+	temp := this.outboxCollection().FindOneAndUpdate(ctx,
+		bson.M{outboxStateKey: model.OutboxJobPending, outboxNextAttemptAtKey: bson.M{"$lte": now}},
+		bson.M{
+			"$set": bson.M{outboxNextAttemptAtKey: now.Add(lease)},
+			"$inc": bson.M{outboxAttemptsKey: 1},
+		},
+		options.FindOneAndUpdate().SetSort(bson.D{{Key: outboxNextAttemptAtKey, Value: 1}}).SetReturnDocument(options.After))
+	err = temp.Err()
+	if err == mongo.ErrNoDocuments {
+		return job, false, nil
+	}
+	if err != nil {
+		return job, false, err
+	}
+	err = temp.Decode(&job)
+	if err != nil {
+		return job, false, err
+	}
+	return job, true, nil
+}
+
+func (this *Mongo) SetOutboxJob(job model.OutboxJob) error {
+	ctx, _ := getTimeoutContext()
+	_, err := this.outboxCollection().ReplaceOne(ctx, bson.M{outboxIdKey: job.Id}, job)
+	return err
+}
+
+func (this *Mongo) ListOutboxJobs(userId string, notificationId string) (result []model.OutboxJob, err error, errCode int) {
+	result = []model.OutboxJob{}
+	ctx, _ := getTimeoutContext()
+	cursor, err := this.outboxCollection().Find(ctx,
+		bson.M{outboxUserIdKey: userId, outboxNotificationIdKey: notificationId},
+		options.Find().SetSort(bson.D{{Key: outboxCreatedAtKey, Value: 1}, {Key: outboxIdKey, Value: 1}}))
+	if err != nil {
+		return result, err, http.StatusInternalServerError
+	}
+	err = cursor.All(ctx, &result)
+	if err != nil {
+		return result, err, http.StatusInternalServerError
+	}
+	return result, nil, http.StatusOK
+}
@@ -25,6 +25,7 @@ import (
 )
 
 var settingsUserIdKey = "user_id"
+var settingsRetentionKey = "retention_days"
 
 func initSettings() {
 	CreateCollections = append(CreateCollections, func(db *Mongo) error {
@@ -75,3 +76,22 @@ func (this *Mongo) SetSettings(settings model.Settings) (error, int) {
 	}
 	return nil, http.StatusOK
 }
+
+func (this *Mongo) ListSettingsWithRetention() (result []model.Settings, err error) {
+	result = []model.Settings{}
+	ctx, _ := getTimeoutContext()
+	cursor, err := this.settingsCollection().Find(ctx, bson.M{settingsRetentionKey: bson.M{"$exists": true, "$ne": bson.M{}}})
+	if err != nil {
+		return result, err
+	}
+	for cursor.Next(ctx) {
+		element := model.Settings{}
+		err = cursor.Decode(&element)
+		if err != nil {
+			return result, err
+		}
+		result = append(result, element)
+	}
+	err = cursor.Err()
+	return
+}
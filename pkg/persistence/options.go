@@ -16,7 +16,27 @@
 
 package persistence
 
+import "time"
+
+const SortByCreatedAt = "created_at"
+const SortByTitle = "title"
+
 type ListOptions struct {
 	Limit  int
 	Offset int
+
+	// UseCursor replaces Offset with keyset paging on created_at and id; an empty Cursor requests the first page
+	UseCursor bool
+	Cursor    string
+	SkipTotal bool
+
+	// filters and sorting below are only used when listing notifications
+	Search        string // full-text search on title and message, sorts by relevance unless SortBy is set
+	IsRead        *bool
+	Labels        map[string]string // notifications must contain all labels in their metadata
+	Topics        []string
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	SortBy        string // SortByCreatedAt (default) or SortByTitle
+	SortDesc      bool
 }
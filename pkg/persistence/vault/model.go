@@ -3,16 +3,22 @@ package vault
 import "github.com/SENERGY-Platform/notifier/pkg/model"
 
 type SecretBrokerData struct {
-	Address  string `json:"address"`
-	User     string `json:"user"`
-	Password string `json:"password"`
+	Address    string `json:"address"`
+	User       string `json:"user"`
+	Password   string `json:"password"`
+	CaCert     string `json:"ca_cert,omitempty"`
+	ClientCert string `json:"client_cert,omitempty"`
+	ClientKey  string `json:"client_key,omitempty"`
 }
 
 func secretBrokerDataFromBroker(broker *model.Broker) *SecretBrokerData {
 	return &SecretBrokerData{
-		Address:  broker.Address,
-		User:     broker.User,
-		Password: broker.Password,
+		Address:    broker.Address,
+		User:       broker.User,
+		Password:   broker.Password,
+		CaCert:     broker.CaCert,
+		ClientCert: broker.ClientCert,
+		ClientKey:  broker.ClientKey,
 	}
 }
 
@@ -20,12 +26,18 @@ func (secret *SecretBrokerData) fillModel(broker *model.Broker) {
 	broker.Address = secret.Address
 	broker.User = secret.User
 	broker.Password = secret.Password
+	broker.CaCert = secret.CaCert
+	broker.ClientCert = secret.ClientCert
+	broker.ClientKey = secret.ClientKey
 }
 
 func stripBroker(broker *model.Broker) {
 	broker.Address = ""
 	broker.User = ""
 	broker.Password = ""
+	broker.CaCert = ""
+	broker.ClientCert = ""
+	broker.ClientKey = ""
 }
 
 func NeedsMigration(broker *model.Broker) bool {
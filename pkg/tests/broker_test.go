@@ -126,6 +126,36 @@ func TestBrokerCRUD(t *testing.T) {
 		Brokers: []model.Broker{test1},
 	}))
 
+	t.Run("tls broker", func(t *testing.T) {
+		test4, err := createBroker(conf, "user3", model.Broker{
+			Address:  "ssl://test4",
+			User:     "test4",
+			Password: "testpw",
+			Insecure: true,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		err = readBroker(conf, "user3", test4.Id, test4)
+		if err != nil {
+			t.Error(err)
+		}
+		_, err = createBroker(conf, "user3", model.Broker{
+			Address: "ssl://test5",
+			CaCert:  "not a certificate",
+		})
+		if err == nil {
+			t.Error("was allowed to use invalid ca certificate")
+		}
+		_, err = createBroker(conf, "user3", model.Broker{
+			Address: "http://test6",
+		})
+		if err == nil {
+			t.Error("was allowed to use unsupported url scheme")
+		}
+	})
+
 	// Try disallowed actions
 
 	_, err = createBroker(conf, "user2", model.Broker{
@@ -151,6 +181,97 @@ func TestBrokerCRUD(t *testing.T) {
 	}
 }
 
+func TestBrokerAutoDisable(t *testing.T) {
+	wg, ctx, cancel, conf, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wg.Wait()
+	defer cancel()
+
+	conf.BrokerMaxConsecutiveFailures = 2
+	conf.OutboxPollInterval = "100ms"
+	conf.OutboxRetryBackoff = "100ms"
+	conf.OutboxMaxRetryBackoff = "100ms"
+	conf.OutboxMaxAttempts = 3
+
+	err = pkg.Start(ctx, wg, conf)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	broker, err := createBroker(conf, "user1", model.Broker{
+		Address: "localhost:1",
+		Topic:   "test",
+		Enabled: true,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	// retries of a notification do not count as further failures
+	_, err = createNotification(conf, "user1", model.Notification{Title: "test"}, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	time.Sleep(2 * time.Second)
+	broker, err = getBroker(conf, "user1", broker.Id)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !broker.Enabled || broker.Health.ConsecutiveFailures != 1 || broker.Health.LastError == "" {
+		t.Error("expected one failure for the retried notification", broker.Enabled, broker.Health)
+	}
+
+	_, err = createNotification(conf, "user1", model.Notification{Title: "test"}, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	time.Sleep(time.Second)
+
+	broker, err = getBroker(conf, "user1", broker.Id)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if broker.Enabled {
+		t.Error("failing broker not disabled")
+	}
+	if broker.Health.ConsecutiveFailures != 2 || broker.Health.LastError == "" || broker.Health.LastErrorAt == nil || broker.Health.DisabledAt == nil {
+		t.Error("unexpected broker health", broker.Health)
+	}
+
+	list, err := listNotificationsPage(conf, "user1", "")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	found := false
+	for _, notification := range list.Notifications {
+		if notification.Topic == model.TopicDeveloper && notification.CorrelationKey == "broker_disabled_"+broker.Id {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("user was not notified about the disabled broker", list.Notifications)
+	}
+
+	broker.Enabled = true
+	broker, err = updateBroker(conf, "user1", broker)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if broker.Health.ConsecutiveFailures != 0 || broker.Health.DisabledAt != nil || broker.Health.LastError == "" {
+		t.Error("unexpected broker health after enabling", broker.Health)
+	}
+}
+
 func listBrokers(config configuration.Config, userId string, expected model.BrokerList) func(t *testing.T) {
 	return func(t *testing.T) {
 		token, err := createToken(userId)
@@ -338,3 +459,60 @@ func deleteBroker(config configuration.Config, userId string, id string) error {
 	}
 	return nil
 }
+
+func testBroker(config configuration.Config, userId string, id string, broker *model.Broker) (result model.BrokerTestResult, err error) {
+	token, err := createToken(userId)
+	if err != nil {
+		return
+	}
+	url := "http://localhost:" + config.ApiPort + "/brokers/" + id + "/test"
+	b := new(bytes.Buffer)
+	if broker != nil {
+		url = "http://localhost:" + config.ApiPort + "/brokers/test"
+		err = json.NewEncoder(b).Encode(broker)
+		if err != nil {
+			return
+		}
+	}
+	req, err := http.NewRequest("POST", url, b)
+	if err != nil {
+		return
+	}
+	ctx, _ := context.WithTimeout(context.Background(), 40*time.Second)
+	req.WithContext(ctx)
+	req.Header.Set("Authorization", token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return result, errors.New(string(b))
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	return
+}
+
+func getBroker(config configuration.Config, userId string, id string) (result model.Broker, err error) {
+	token, err := createToken(userId)
+	if err != nil {
+		return result, err
+	}
+	req, err := http.NewRequest("GET", "http://localhost:"+config.ApiPort+"/brokers/"+id, nil)
+	if err != nil {
+		return result, err
+	}
+	ctx, _ := context.WithTimeout(context.Background(), 5*time.Second)
+	req.WithContext(ctx)
+	req.Header.Set("Authorization", token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return result, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return result, errors.New(string(b))
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	return result, err
+}
@@ -22,6 +22,7 @@ import (
 	"github.com/testcontainers/testcontainers-go/wait"
 	"log"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -97,6 +98,40 @@ func MqttContainer(ctx context.Context, wg *sync.WaitGroup) (brokerAddress strin
 	return brokerAddress, err
 }
 
+// KafkaContainer starts a kafka compatible redpanda broker
+func KafkaContainer(ctx context.Context, wg *sync.WaitGroup) (kafkaUrl string, err error) {
+	log.Println("start kafka")
+	port, err := getFreePort()
+	if err != nil {
+		return "", err
+	}
+	kafkaUrl = "localhost:" + strconv.Itoa(port)
+	c, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "docker.redpanda.com/redpandadata/redpanda:v23.3.5",
+			ExposedPorts: []string{strconv.Itoa(port) + ":9092/tcp"}, // fixed port, the advertised address has to be known on start
+			Cmd: []string{"redpanda", "start", "--mode", "dev-container", "--smp", "1",
+				"--kafka-addr", "PLAINTEXT://0.0.0.0:9092", "--advertise-kafka-addr", "PLAINTEXT://" + kafkaUrl},
+			WaitingFor: wait.ForAll(
+				wait.ForLog("Successfully started Redpanda!"),
+				wait.ForListeningPort("9092/tcp"),
+			),
+		},
+		Started: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		<-ctx.Done()
+		timeout, _ := context.WithTimeout(context.Background(), 10*time.Second)
+		log.Println("DEBUG: remove container kafka", c.Terminate(timeout))
+	}()
+	return kafkaUrl, nil
+}
+
 func getFreePort() (int, error) {
 	addr, err := net.ResolveTCPAddr("tcp", "localhost:0")
 	if err != nil {
@@ -0,0 +1,112 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/SENERGY-Platform/notifier/pkg/model"
+	"github.com/SENERGY-Platform/notifier/pkg/persistence/mongo"
+)
+
+func TestEmailDigestClaims(t *testing.T) {
+	wg, _, cancel, conf, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wg.Wait()
+	defer cancel()
+
+	db, err := mongo.New(conf)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	now := time.Now().Truncate(time.Millisecond)
+	lease := time.Minute
+	entries := []model.EmailDigestEntry{
+		{UserId: "user1", NotificationId: "n1", DueAt: now.Add(-time.Minute)},
+		{UserId: "user1", NotificationId: "n2", DueAt: now.Add(-time.Minute)},
+		{UserId: "user1", NotificationId: "n1", DueAt: now.Add(-time.Minute)}, // already queued
+		{UserId: "user1", NotificationId: "n3", DueAt: now.Add(time.Hour)},
+	}
+	for _, entry := range entries {
+		err = db.QueueEmailDigestEntry(entry)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	claim := func(t *testing.T, at time.Time, expectedIds []string, expectedAttempts int) (digestId string) {
+		t.Helper()
+		digests, err := db.ClaimDueEmailDigests(at, lease, 10)
+		if err != nil {
+			t.Error(err)
+			return ""
+		}
+		if len(expectedIds) == 0 {
+			if len(digests) != 0 {
+				t.Error("expected no claimable digest", digests)
+			}
+			return ""
+		}
+		if len(digests) != 1 {
+			t.Error("expected one digest", digests)
+			return ""
+		}
+		ids := slices.Clone(digests[0].NotificationIds)
+		slices.Sort(ids)
+		if digests[0].UserId != "user1" || !slices.Equal(ids, expectedIds) || digests[0].Attempts != expectedAttempts {
+			t.Error("unexpected digest", digests[0])
+		}
+		return digests[0].Id
+	}
+
+	var digestId string
+	t.Run("claim due entries", func(t *testing.T) {
+		digestId = claim(t, now, []string{"n1", "n2"}, 0)
+	})
+	t.Run("lease blocks second claim", func(t *testing.T) {
+		claim(t, now.Add(lease/2), nil, 0)
+	})
+	t.Run("release after failed send", func(t *testing.T) {
+		err = db.ReleaseEmailDigest(digestId, now.Add(time.Second))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		claim(t, now, nil, 0) // not yet due again
+		digestId = claim(t, now.Add(time.Second), []string{"n1", "n2"}, 1)
+	})
+	t.Run("stale claim is claimed again", func(t *testing.T) {
+		claim(t, now.Add(time.Second+lease/2), nil, 0)
+		digestId = claim(t, now.Add(time.Second+lease), []string{"n1", "n2"}, 1)
+	})
+	t.Run("complete", func(t *testing.T) {
+		err = db.CompleteEmailDigest(digestId, now.Add(2*time.Second))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		claim(t, now.Add(3*lease), nil, 0)
+		claim(t, now.Add(time.Hour), []string{"n3"}, 0)
+	})
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright 2026 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package tests
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/SENERGY-Platform/notifier/pkg"
+	"github.com/segmentio/kafka-go"
+)
+
+func TestKafkaConsumer(t *testing.T) {
+	wg, ctx, cancel, conf, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wg.Wait()
+	defer cancel()
+
+	conf.KafkaUrl, err = KafkaContainer(ctx, wg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = pkg.Start(ctx, wg, conf)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	writer := &kafka.Writer{
+		Addr:     kafka.TCP(conf.KafkaUrl),
+		Topic:    conf.KafkaNotificationTopic,
+		Balancer: &kafka.LeastBytes{},
+	}
+	defer writer.Close()
+	messages := []string{
+		`{"userId":"user1","title":"kafka","topic":"developer","ignore_duplicates_within_seconds":60}`,
+		`{"userId":"user1","title":"kafka","topic":"developer","ignore_duplicates_within_seconds":60}`,
+		`not json`,
+		`{"title":"missing user"}`,
+		`{"userId":"unknown-user","title":"unknown user"}`,
+		`{"userId":"user2","title":"kafka2"}`,
+	}
+	for _, message := range messages {
+		err = writer.WriteMessages(ctx, kafka.Message{Value: []byte(message)})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+
+	time.Sleep(10 * time.Second) // consumer group join and rebalance
+
+	list, err := listNotificationsPage(conf, "user1", "")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(list.Notifications) != 1 || list.Notifications[0].Title != "kafka" || list.Notifications[0].Occurrences != 2 {
+		t.Error("unexpected notifications of user1", list.Notifications)
+	}
+	list, err = listNotificationsPage(conf, "user2", "")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(list.Notifications) != 1 || list.Notifications[0].Title != "kafka2" {
+		t.Error("unexpected notifications of user2", list.Notifications)
+	}
+
+	t.Run("dead letters", func(t *testing.T) {
+		reader := kafka.NewReader(kafka.ReaderConfig{
+			Brokers:   []string{conf.KafkaUrl},
+			Topic:     conf.KafkaDeadLetterTopic,
+			Partition: 0,
+			MaxWait:   time.Second,
+		})
+		defer reader.Close()
+		expected := []string{messages[2], messages[3], messages[4]}
+		for i, value := range expected {
+			timeout, _ := context.WithTimeout(ctx, 10*time.Second)
+			msg, err := reader.ReadMessage(timeout)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if string(msg.Value) != value {
+				t.Error("unexpected dead letter", string(msg.Value), value)
+			}
+			headers := map[string]string{}
+			for _, header := range msg.Headers {
+				headers[header.Key] = string(header.Value)
+			}
+			if headers["error"] == "" || headers["original_topic"] != conf.KafkaNotificationTopic || headers["original_offset"] != strconv.Itoa(i+2) {
+				t.Error("unexpected dead letter headers", headers)
+			}
+		}
+	})
+
+	t.Run("offsets committed", func(t *testing.T) {
+		client := &kafka.Client{Addr: kafka.TCP(conf.KafkaUrl)}
+		timeout, _ := context.WithTimeout(ctx, 10*time.Second)
+		resp, err := client.OffsetFetch(timeout, &kafka.OffsetFetchRequest{
+			GroupID: conf.KafkaConsumerGroup,
+			Topics:  map[string][]int{conf.KafkaNotificationTopic: {0}},
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		partitions := resp.Topics[conf.KafkaNotificationTopic]
+		if len(partitions) != 1 || partitions[0].CommittedOffset != int64(len(messages)) {
+			t.Error("unexpected committed offsets", partitions)
+		}
+	})
+}
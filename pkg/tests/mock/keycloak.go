@@ -12,6 +12,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"runtime/debug"
+	"strings"
 	"time"
 )
 
@@ -53,5 +54,29 @@ func getKeycloakRouter() (router *mux.Router, err error) {
 		}
 	}).Methods(http.MethodPost)
 
+	// users with an id starting with "unknown" do not exist, users with an id starting with "mail" have a verified email address
+	router.HandleFunc("/auth/admin/realms/master/users/{id}", func(writer http.ResponseWriter, request *http.Request) {
+		id := mux.Vars(request)["id"]
+		writer.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if strings.HasPrefix(id, "unknown") {
+			writer.WriteHeader(http.StatusNotFound)
+			_, _ = writer.Write([]byte(`{"error":"User not found"}`))
+			return
+		}
+		user := map[string]interface{}{
+			"id":       id,
+			"username": id,
+			"enabled":  true,
+		}
+		if strings.HasPrefix(id, "mail") {
+			user["email"] = id + "@example.com"
+			user["emailVerified"] = true
+		}
+		err = json.NewEncoder(writer).Encode(user)
+		if err != nil {
+			log.Println("ERROR: unable to encode response", err)
+		}
+	}).Methods(http.MethodGet)
+
 	return
 }
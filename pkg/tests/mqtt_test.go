@@ -18,12 +18,18 @@ package tests
 
 import (
 	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/SENERGY-Platform/notifier/pkg"
 	"github.com/SENERGY-Platform/notifier/pkg/model"
 	"github.com/SENERGY-Platform/notifier/pkg/mqtt"
+	"github.com/eclipse/paho.golang/autopaho"
+	paho5 "github.com/eclipse/paho.golang/paho"
 	paho "github.com/eclipse/paho.mqtt.golang"
 	"github.com/google/uuid"
 )
@@ -56,7 +62,7 @@ func TestMQTT(t *testing.T) {
 	}
 	topic1, topic2, topic3 := "topic1", "topic2", "topic3"
 
-	_, err = createBroker(conf, "user1", model.Broker{
+	broker1, err := createBroker(conf, "user1", model.Broker{
 		Address: conf.PlatformMqttAddress,
 		Topic:   topic1,
 		Qos:     2,
@@ -137,6 +143,36 @@ func TestMQTT(t *testing.T) {
 		t.Error("user2 received mqtt notification of user1")
 	}
 
+	t.Run("deliveries", func(t *testing.T) {
+		deliveries, err := listDeliveries(conf, "user1", test1.Id)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		targets := map[string]bool{}
+		for _, attempt := range deliveries.Attempts {
+			if attempt.Channel == model.ChannelMqtt {
+				targets[attempt.Target] = attempt.Success
+			}
+		}
+		if len(targets) != 2 || !targets[model.DeliveryTargetPlatformBroker] || !targets[broker1.Id] {
+			t.Error("unexpected mqtt delivery attempts", deliveries.Attempts)
+		}
+		mqttJobDone := false
+		for _, job := range deliveries.Jobs {
+			if job.Channel == model.ChannelMqtt && job.State == model.OutboxJobDone {
+				mqttJobDone = true
+			}
+		}
+		if !mqttJobDone {
+			t.Error("expected finished mqtt outbox job", deliveries.Jobs)
+		}
+		_, err = listDeliveries(conf, "user2", test1.Id)
+		if err == nil {
+			t.Error("user2 could read deliveries of user1")
+		}
+	})
+
 	test2, err := createNotification(conf, "user2", model.Notification{
 		Title: "test1",
 	}, nil)
@@ -164,4 +200,642 @@ func TestMQTT(t *testing.T) {
 	if len(msgs3) != 1 || msgs3[0] != string(test2S) {
 		t.Error("user2 did not receive mqtt notification on custom broker")
 	}
+
+	t.Run("read state events", func(t *testing.T) {
+		platformEvents, brokerEvents := []string{}, []string{}
+		mqttClient.Subscribe(conf.PlatformMqttBasetopic+"/user1/events", 1, func(_ paho.Client, message paho.Message) {
+			platformEvents = append(platformEvents, string(message.Payload()))
+		})
+		mqttClient.Subscribe(topic1+"/events", 1, func(_ paho.Client, message paho.Message) {
+			brokerEvents = append(brokerEvents, string(message.Payload()))
+		})
+		beforeU1, before1 := len(msgsU1), len(msgs1)
+		_, err = setNotificationsReadState(conf, "user1", model.NotificationReadStateRequest{IsRead: true, Ids: []string{test1.Id}})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		time.Sleep(time.Second)
+		if len(msgsU1) != beforeU1 || len(msgs1) != before1 {
+			t.Error("read state event published on notification topic")
+		}
+		if len(platformEvents) != 1 || len(brokerEvents) != 1 {
+			t.Error("read state event not published on event topic", platformEvents, brokerEvents)
+		}
+	})
+
+	t.Run("pooled connection uses updated broker", func(t *testing.T) {
+		topic1Updated := "topic1-updated"
+		msgs1Updated := []string{}
+		mqttClient.Subscribe(topic1Updated, 1, func(_ paho.Client, message paho.Message) {
+			msgs1Updated = append(msgs1Updated, string(message.Payload()))
+		})
+		broker1.Topic = topic1Updated
+		_, err = updateBroker(conf, "user1", broker1)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_, err = createNotification(conf, "user1", model.Notification{
+			Title: "test3",
+			Topic: model.TopicDeveloper,
+		}, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		time.Sleep(time.Second)
+		if len(msgs1) != 1 {
+			t.Error("user1 received mqtt notification on outdated topic")
+		}
+		if len(msgs1Updated) != 1 {
+			t.Error("user1 did not receive mqtt notification on updated topic")
+		}
+	})
+
+	t.Run("test broker", func(t *testing.T) {
+		testMsgs := []string{}
+		mqttClient.Subscribe("topic-test", 1, func(_ paho.Client, message paho.Message) {
+			testMsgs = append(testMsgs, string(message.Payload()))
+		})
+		broker1.Topic = "topic-test"
+		_, err = updateBroker(conf, "user1", broker1)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		result, err := testBroker(conf, "user1", broker1.Id, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if !result.Connected || !result.Published || result.Error != "" {
+			t.Error("unexpected test result", result)
+		}
+		time.Sleep(time.Second)
+		if len(testMsgs) != 1 {
+			t.Error("test message not received", testMsgs)
+		}
+
+		// the test message uses the payload format of the broker
+		plain := broker1
+		plain.PayloadFormat = model.MqttPayloadFormatPlain
+		result, err = testBroker(conf, "user1", "", &plain)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		time.Sleep(time.Second)
+		if !result.Published || len(testMsgs) != 2 || testMsgs[1] != "Broker test\nTest message of the notifier" {
+			t.Error("unexpected plain test message", result, testMsgs)
+		}
+		_, err = testBroker(conf, "user2", broker1.Id, nil)
+		if err == nil {
+			t.Error("user2 could test broker of user1")
+		}
+
+		result, err = testBroker(conf, "user1", "", &model.Broker{Address: "tcp://localhost:1", Topic: "topic-test"})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if result.Connected || result.Published || result.Error == "" {
+			t.Error("unexpected test result for unreachable broker", result)
+		}
+	})
+
+	t.Run("topic template and filter", func(t *testing.T) {
+		routed := map[string]int{}
+		mux := sync.Mutex{}
+		mqttClient.Subscribe("routed/#", 1, func(_ paho.Client, message paho.Message) {
+			mux.Lock()
+			defer mux.Unlock()
+			routed[message.Topic()]++
+		})
+		_, err = createBroker(conf, "user1", model.Broker{
+			Address: conf.PlatformMqttAddress,
+			Topic:   "routed/{{.Topic}}/{{.Severity}}",
+			Topics:  []model.Topic{model.TopicIncident},
+			Enabled: true,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		unfiltered, err := createBroker(conf, "user1", model.Broker{
+			Address: conf.PlatformMqttAddress,
+			Topic:   "routed/{{.Topic}}",
+			Enabled: true,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_, err = createBroker(conf, "user1", model.Broker{
+			Address: conf.PlatformMqttAddress,
+			Topic:   "routed/{{.Unknown}}",
+		})
+		if err == nil {
+			t.Error("was allowed to use invalid topic template")
+		}
+		for _, topic := range []model.Topic{model.TopicIncident, model.TopicDeveloper} {
+			_, err = createNotification(conf, "user1", model.Notification{
+				Title:    "routed",
+				Topic:    topic,
+				Severity: model.SeverityError,
+			}, nil)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		time.Sleep(time.Second)
+		mux.Lock()
+		if len(routed) != 3 || routed["routed/incident/error"] != 1 || routed["routed/incident"] != 1 || routed["routed/developer"] != 1 {
+			t.Error("unexpected routed messages", routed)
+		}
+		mux.Unlock()
+
+		// read state events have no notification to render the topic templates with
+		_, err = setNotificationsReadState(conf, "user1", model.NotificationReadStateRequest{IsRead: true, Filter: &model.NotificationFilter{}})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		time.Sleep(time.Second)
+		mux.Lock()
+		if len(routed) != 3 {
+			t.Error("read state event published to topic template", routed)
+		}
+		mux.Unlock()
+		unfiltered, err = getBroker(conf, "user1", unfiltered.Id)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if unfiltered.Health.ConsecutiveFailures != 0 || unfiltered.Health.LastError != "" {
+			t.Error("unexpected broker health", unfiltered.Health)
+		}
+	})
+
+	t.Run("plain payload with retain", func(t *testing.T) {
+		_, err = createBroker(conf, "user1", model.Broker{
+			Address: conf.PlatformMqttAddress,
+			Topic:   "plain",
+			Topics:  []model.Topic{model.TopicProcesses},
+			Enabled: true,
+			MqttPayloadOptions: model.MqttPayloadOptions{
+				PayloadFormat: model.MqttPayloadFormatPlain,
+				Retain:        true,
+			},
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_, err = createNotification(conf, "user1", model.Notification{
+			Title:   "plain title",
+			Message: "plain message",
+			Topic:   model.TopicProcesses,
+		}, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		time.Sleep(time.Second)
+		// subscribed after publish, only receives the retained message
+		retained := make(chan string, 1)
+		mqttClient.Subscribe("plain", 1, func(_ paho.Client, message paho.Message) {
+			select {
+			case retained <- string(message.Payload()):
+			default:
+			}
+		})
+		select {
+		case msg := <-retained:
+			if msg != "plain title\nplain message" {
+				t.Error("unexpected plain payload", msg)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("retained message not received")
+		}
+	})
+
+	t.Run("mqtt v5 properties", func(t *testing.T) {
+		received := make(chan *paho5.Publish, 1)
+		serverUrl, err := url.Parse(conf.PlatformMqttAddress)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		subscriber, err := autopaho.NewConnection(ctx, autopaho.ClientConfig{
+			ServerUrls:                    []*url.URL{serverUrl},
+			KeepAlive:                     30,
+			CleanStartOnInitialConnection: true,
+			ClientConfig: paho5.ClientConfig{
+				ClientID: "notifier-test-" + uuid.NewString(),
+				OnPublishReceived: []func(paho5.PublishReceived) (bool, error){func(r paho5.PublishReceived) (bool, error) {
+					select {
+					case received <- r.Packet:
+					default:
+					}
+					return true, nil
+				}},
+			},
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		err = subscriber.AwaitConnection(ctx)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_, err = subscriber.Subscribe(ctx, &paho5.Subscribe{Subscriptions: []paho5.SubscribeOptions{{Topic: "v5", QoS: 1}}})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+
+		_, err = createBroker(conf, "user1", model.Broker{
+			Address:              conf.PlatformMqttAddress,
+			Topic:                "v5",
+			Qos:                  1,
+			Enabled:              true,
+			ProtocolVersion:      model.MqttProtocolVersion5,
+			MessageExpirySeconds: 60,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_, err = createBroker(conf, "user1", model.Broker{
+			Address:              conf.PlatformMqttAddress,
+			Topic:                "v3",
+			MessageExpirySeconds: 60,
+		})
+		if err == nil {
+			t.Error("was allowed to use message expiry without mqtt v5")
+		}
+		notification, err := createNotification(conf, "user1", model.Notification{
+			Title: "v5",
+			Topic: model.TopicDeveloper,
+		}, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		select {
+		case msg := <-received:
+			if msg.Properties == nil {
+				t.Error("missing properties")
+				return
+			}
+			if msg.Properties.ContentType != "application/json" {
+				t.Error("unexpected content type", msg.Properties.ContentType)
+			}
+			if msg.Properties.MessageExpiry == nil || *msg.Properties.MessageExpiry > 60 {
+				t.Error("unexpected message expiry", msg.Properties.MessageExpiry)
+			}
+			if msg.Properties.User.Get("notification_id") != notification.Id ||
+				msg.Properties.User.Get("topic") != string(model.TopicDeveloper) ||
+				msg.Properties.User.Get("created_at") == "" {
+				t.Error("unexpected user properties", msg.Properties.User)
+			}
+		case <-time.After(5 * time.Second):
+			t.Error("mqtt v5 message not received")
+		}
+	})
+
+	t.Run("min severity", func(t *testing.T) {
+		settings := model.DefaultSettings()
+		settings.ChannelMinSeverity = map[model.Channel]model.Severity{model.ChannelMqtt: model.SeverityWarning}
+		_, err = setSettings(conf, "user1", settings)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		before := len(msgsU1)
+		info, err := createNotification(conf, "user1", model.Notification{Title: "info", Severity: model.SeverityInfo}, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_, err = createNotification(conf, "user1", model.Notification{Title: "error", Severity: model.SeverityError}, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		time.Sleep(time.Second)
+		if len(msgsU1) != before+1 {
+			t.Error("expected only the error notification on the platform broker", msgsU1[before:])
+		}
+
+		settings.ChannelMinSeverity[model.ChannelMqtt] = model.SeverityInfo
+		_, err = setSettings(conf, "user1", settings)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		info.Severity = "" // clients not aware of severities
+		info.IsRead = true
+		err = updateNotification(conf, "user1", info)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		time.Sleep(time.Second)
+		if len(msgsU1) != before+2 {
+			t.Error("update without severity was not delivered with min severity info", msgsU1[before:])
+		}
+	})
+}
+
+func TestMqttPushDuplicates(t *testing.T) {
+	wg, ctx, cancel, conf, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wg.Wait()
+	defer cancel()
+
+	conf.PlatformMqttAddress, err = MqttContainer(ctx, wg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	err = pkg.Start(ctx, wg, conf)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = setPlatformBroker(conf, "user1", model.PlatformBroker{
+		Enabled: true,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mux := sync.Mutex{}
+	msgs := []model.Notification{}
+	publisher, err := mqtt.NewPublisher(ctx, conf.PlatformMqttAddress, conf.PlatformMqttUser,
+		conf.PlatformMqttPw, "notifier-test-"+uuid.NewString(), conf.PlatformMqttQos, conf.Debug)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	publisher.GetClient().Subscribe(conf.PlatformMqttBasetopic+"/user1", 1, func(_ paho.Client, message paho.Message) {
+		notification := model.Notification{}
+		err := json.Unmarshal(message.Payload(), &notification)
+		if err != nil {
+			t.Error(err)
+		}
+		mux.Lock()
+		defer mux.Unlock()
+		msgs = append(msgs, notification)
+	})
+
+	duplicate := model.Notification{Title: "duplicate", Topic: model.TopicDeveloper}
+	window := int64(60)
+	first, err := createNotification(conf, "user1", duplicate, &window)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	time.Sleep(time.Second)
+
+	pushed, err := createNotificationWithQuery(conf, "user1", duplicate, "ignore_duplicates_within_seconds=60&push_duplicates=true")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if pushed.Id != first.Id || pushed.Occurrences != 2 {
+		t.Error("expected occurrences of the existing notification to be counted", pushed)
+	}
+	time.Sleep(time.Second)
+
+	suppressed, err := createNotification(conf, "user1", duplicate, &window)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if suppressed.Id != first.Id || suppressed.Occurrences != 3 {
+		t.Error("expected occurrences of the existing notification to be counted", suppressed)
+	}
+	time.Sleep(time.Second)
+
+	mux.Lock()
+	defer mux.Unlock()
+	if len(msgs) != 2 {
+		t.Error("expected the first notification and the pushed duplicate", msgs)
+		return
+	}
+	if msgs[0].Id != first.Id || msgs[1].Id != first.Id || msgs[1].Occurrences != 2 {
+		t.Error("unexpected mqtt messages", msgs)
+	}
+}
+
+func TestMqttOutboxRetrySkipsDeliveredBrokers(t *testing.T) {
+	wg, ctx, cancel, conf, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wg.Wait()
+	defer cancel()
+
+	conf.PlatformMqttAddress, err = MqttContainer(ctx, wg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	conf.OutboxPollInterval = "100ms"
+	conf.OutboxRetryBackoff = "100ms"
+	conf.OutboxMaxRetryBackoff = "100ms"
+	conf.OutboxMaxAttempts = 3
+
+	err = pkg.Start(ctx, wg, conf)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = setPlatformBroker(conf, "user1", model.PlatformBroker{
+		Enabled: true,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	working, err := createBroker(conf, "user1", model.Broker{
+		Address: conf.PlatformMqttAddress,
+		Topic:   "working",
+		Qos:     2,
+		Enabled: true,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	failing, err := createBroker(conf, "user1", model.Broker{
+		Address: "localhost:1",
+		Topic:   "failing",
+		Enabled: true,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	mux := sync.Mutex{}
+	platformMsgs, workingMsgs := 0, 0
+	publisher, err := mqtt.NewPublisher(ctx, conf.PlatformMqttAddress, conf.PlatformMqttUser,
+		conf.PlatformMqttPw, "notifier-test-"+uuid.NewString(), conf.PlatformMqttQos, conf.Debug)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	mqttClient := publisher.GetClient()
+	mqttClient.Subscribe(conf.PlatformMqttBasetopic+"/user1", 1, func(_ paho.Client, message paho.Message) {
+		mux.Lock()
+		defer mux.Unlock()
+		platformMsgs++
+	})
+	mqttClient.Subscribe("working", 1, func(_ paho.Client, message paho.Message) {
+		mux.Lock()
+		defer mux.Unlock()
+		workingMsgs++
+	})
+
+	notification, err := createNotification(conf, "user1", model.Notification{Title: "retry"}, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	time.Sleep(3 * time.Second)
+
+	mux.Lock()
+	if platformMsgs != 1 {
+		t.Error("expected exactly one message on platform broker, got", platformMsgs)
+	}
+	if workingMsgs != 1 {
+		t.Error("expected exactly one message on working broker, got", workingMsgs)
+	}
+	mux.Unlock()
+
+	deliveries, err := listDeliveries(conf, "user1", notification.Id)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	failedAttempts := 0
+	for _, attempt := range deliveries.Attempts {
+		if attempt.Target == failing.Id && !attempt.Success {
+			failedAttempts++
+		}
+	}
+	if failedAttempts != 3 {
+		t.Error("expected 3 failed attempts on failing broker, got", failedAttempts, deliveries.Attempts)
+	}
+	for _, job := range deliveries.Jobs {
+		if job.Channel != model.ChannelMqtt {
+			continue
+		}
+		if job.State != model.OutboxJobDeadLetter {
+			t.Error("expected dead letter mqtt job", job)
+		}
+		if len(job.DeliveredTargets) != 2 || !slices.Contains(job.DeliveredTargets, working.Id) || !slices.Contains(job.DeliveredTargets, model.DeliveryTargetPlatformBroker) {
+			t.Error("unexpected delivered targets", job.DeliveredTargets)
+		}
+	}
+}
+
+func TestMqttIngestion(t *testing.T) {
+	wg, ctx, cancel, conf, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wg.Wait()
+	defer cancel()
+
+	conf.PlatformMqttAddress, err = MqttContainer(ctx, wg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	conf.MqttIngestionTopic = "notifier/create/{userId}"
+	conf.MqttIngestionErrorTopic = "notifier/errors"
+
+	err = pkg.Start(ctx, wg, conf)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	publisher, err := mqtt.NewPublisher(ctx, conf.PlatformMqttAddress, conf.PlatformMqttUser,
+		conf.PlatformMqttPw, "notifier-test-"+uuid.NewString(), 1, conf.Debug)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ingestionErrors := make(chan model.IngestionError, 10)
+	publisher.GetClient().Subscribe(conf.MqttIngestionErrorTopic, 1, func(_ paho.Client, message paho.Message) {
+		ingestionError := model.IngestionError{}
+		_ = json.Unmarshal(message.Payload(), &ingestionError)
+		ingestionErrors <- ingestionError
+	}).Wait()
+
+	t.Run("valid", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			err = publisher.Publish("notifier/create/user1", `{"title":"ingested","topic":"developer","ignore_duplicates_within_seconds":60}`)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		time.Sleep(time.Second)
+		list, err := listNotificationsPage(conf, "user1", "")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(list.Notifications) != 1 || list.Notifications[0].Title != "ingested" || list.Notifications[0].Topic != model.TopicDeveloper {
+			t.Error("unexpected notifications", list.Notifications)
+		}
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		payloads := []string{
+			`not json`,
+			`{"title":"unknown field","foo":"bar"}`,
+			`{"title":"other user","userId":"user2"}`,
+			`{"title":"bad topic","topic":"foo"}`,
+		}
+		for _, payload := range payloads {
+			err = publisher.Publish("notifier/create/user1", payload)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			select {
+			case ingestionError := <-ingestionErrors:
+				if ingestionError.Payload != payload || ingestionError.Topic != "notifier/create/user1" || ingestionError.Error == "" || ingestionError.Code != http.StatusBadRequest {
+					t.Error("unexpected ingestion error", ingestionError)
+				}
+			case <-time.After(5 * time.Second):
+				t.Error("missing ingestion error for", payload)
+			}
+		}
+		list, err := listNotificationsPage(conf, "user2", "")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(list.Notifications) != 0 {
+			t.Error("unexpected notifications of user2", list.Notifications)
+		}
+	})
 }
@@ -26,7 +26,13 @@ import (
 	"github.com/SENERGY-Platform/notifier/pkg/model"
 	"io"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 )
@@ -204,9 +210,16 @@ func TestNotificationCRUD(t *testing.T) {
 		if err != nil {
 			t.Error(err)
 		}
-		if !testNoDupe.Equal(testDupe) {
+		if testNoDupe.Id != testDupe.Id {
 			t.Error("duplicate created even though ignoreDuplicatesWithinSeconds specified")
 		}
+		if testDupe.Occurrences != 2 || testDupe.LastOccurredAt == nil {
+			t.Error("duplicate was not counted", testDupe.Occurrences, testDupe.LastOccurredAt)
+		}
+		err = readNotification(conf, "--", testDupe.Id, testDupe)
+		if err != nil {
+			t.Error(err)
+		}
 
 		testNoDupe2, err := createNotification(conf, "--2", model.Notification{
 			UserId:  "--2",
@@ -221,6 +234,811 @@ func TestNotificationCRUD(t *testing.T) {
 			t.Error("did not create unique notification when ignoreDuplicatesWithinSeconds specified")
 		}
 	})
+
+	t.Run("test link and actions", func(t *testing.T) {
+		actionable, err := createNotification(conf, "user1", model.Notification{
+			Title:   "incident",
+			Message: "process failed",
+			Topic:   model.TopicIncident,
+			Link:    "/processes/monitor/instance-1",
+			Actions: []model.NotificationAction{
+				{Label: "Open process instance", Url: "/processes/monitor/instance-1"},
+				{Label: "Restart", CallbackId: "restart-instance-1"},
+			},
+		}, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		err = readNotification(conf, "user1", actionable.Id, actionable)
+		if err != nil {
+			t.Error(err)
+		}
+		_, err = createNotification(conf, "user1", model.Notification{
+			Title:   "invalid",
+			Actions: []model.NotificationAction{{Label: "missing target"}},
+		}, nil)
+		if err == nil {
+			t.Error("was allowed to create action without url or callback_id")
+		}
+		_, err = createNotification(conf, "user1", model.Notification{
+			Title: "invalid",
+			Link:  "javascript:alert(1)",
+		}, nil)
+		if err == nil {
+			t.Error("was allowed to create javascript link")
+		}
+		actionable.Actions = []model.NotificationAction{{Label: "Open", Url: "data:text/html;base64,PHNjcmlwdD4="}}
+		err = updateNotification(conf, "user1", actionable)
+		if err == nil {
+			t.Error("was allowed to update action with data url")
+		}
+	})
+
+	t.Run("test correlation key", func(t *testing.T) {
+		first, err := createNotification(conf, "user1", model.Notification{
+			Title:          "device offline",
+			Message:        "device-1 is offline",
+			Topic:          model.TopicConnector,
+			CorrelationKey: "device-1-offline",
+		}, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if first.Occurrences != 1 {
+			t.Error("unexpected occurrences", first.Occurrences)
+		}
+		first.IsRead = true
+		err = updateNotification(conf, "user1", first)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		second, err := createNotification(conf, "user1", model.Notification{
+			Title:          "device offline",
+			Message:        "device-1 is still offline",
+			Topic:          model.TopicConnector,
+			CorrelationKey: "device-1-offline",
+		}, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if second.Id != first.Id || second.Occurrences != 2 || second.IsRead || second.Message != "device-1 is still offline" {
+			t.Error("expected existing notification to be replaced", first, second)
+		}
+		other, err := createNotification(conf, "user2", model.Notification{
+			Title:          "device offline",
+			CorrelationKey: "device-1-offline",
+		}, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if other.Id == first.Id || other.Occurrences != 1 {
+			t.Error("correlation key should be scoped by user", other)
+		}
+
+		resolved, err := resolveNotification(conf, "user1", model.NotificationResolveRequest{CorrelationKey: "device-1-offline"})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if resolved.Id != first.Id || resolved.ResolvedAt == nil {
+			t.Error("expected notification to be resolved", resolved)
+		}
+		err = readNotification(conf, "user1", resolved.Id, resolved)
+		if err != nil {
+			t.Error(err)
+		}
+
+		resp, err := http.Post("http://localhost:"+conf.ApiPort+"/notifications/resolve", "application/json", strings.NewReader(`{"correlation_key":"device-1-offline"}`))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Error("expected admin request without user id to be rejected", resp.StatusCode)
+		}
+
+		_, err = resolveNotification(conf, "user1", model.NotificationResolveRequest{CorrelationKey: "device-1-offline", Delete: true})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		_, err = resolveNotification(conf, "user1", model.NotificationResolveRequest{CorrelationKey: "device-1-offline"})
+		if err == nil {
+			t.Error("expected deleted notification to be not found")
+		}
+	})
+
+	t.Run("legacy update keeps server fields", func(t *testing.T) {
+		expiresAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+		created, err := createNotification(conf, "user1", model.Notification{
+			Title:          "disk full",
+			Topic:          model.TopicDeveloper,
+			Severity:       model.SeverityError,
+			Link:           "/devices/device-2",
+			Metadata:       map[string]string{"device_id": "device-2"},
+			ExpiresAt:      &expiresAt,
+			CorrelationKey: "device-2-disk",
+		}, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		// body of clients that only know the original notification fields
+		err = updateNotification(conf, "user1", model.Notification{
+			Id:        created.Id,
+			UserId:    created.UserId,
+			Title:     created.Title,
+			Message:   created.Message,
+			IsRead:    true,
+			CreatedAt: created.CreatedAt,
+			Topic:     created.Topic,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expected := created
+		expected.IsRead = true
+		err = readNotification(conf, "user1", created.Id, expected)
+		if err != nil {
+			t.Error(err)
+		}
+		again, err := createNotification(conf, "user1", model.Notification{
+			Title:          "disk full",
+			Topic:          model.TopicDeveloper,
+			CorrelationKey: "device-2-disk",
+		}, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if again.Id != created.Id || again.Occurrences != 2 {
+			t.Error("expected correlation key to survive the update", again)
+		}
+	})
+}
+
+func TestNotificationListFilter(t *testing.T) {
+	wg, ctx, cancel, conf, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wg.Wait()
+	defer cancel()
+
+	err = pkg.Start(ctx, wg, conf)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	time.Sleep(time.Second)
+
+	now := time.Now().Truncate(time.Millisecond)
+	old, err := createNotification(conf, "user1", model.Notification{
+		Title:     "b",
+		Topic:     model.TopicIncident,
+		CreatedAt: now.Add(-48 * time.Hour),
+	}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	incident, err := createNotification(conf, "user1", model.Notification{
+		Title:     "c",
+		Topic:     model.TopicIncident,
+		CreatedAt: now.Add(-time.Hour),
+	}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	developer, err := createNotification(conf, "user1", model.Notification{
+		Title:     "a",
+		Topic:     model.TopicDeveloper,
+		IsRead:    true,
+		CreatedAt: now,
+	}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	t.Run("sort created_at.desc", listNotificationsQuery(conf, "user1", "sort=created_at.desc", []model.Notification{developer, incident, old}))
+	t.Run("sort title", listNotificationsQuery(conf, "user1", "sort=title", []model.Notification{developer, old, incident}))
+	t.Run("unread", listNotificationsQuery(conf, "user1", "isRead=false", []model.Notification{old, incident}))
+	t.Run("topic", listNotificationsQuery(conf, "user1", "topic="+model.TopicDeveloper, []model.Notification{developer}))
+	t.Run("multiple topics", listNotificationsQuery(conf, "user1", "topic="+model.TopicDeveloper+"&topic="+model.TopicIncident, []model.Notification{old, incident, developer}))
+	t.Run("unread incidents from today", listNotificationsQuery(conf, "user1", "isRead=false&topic="+model.TopicIncident+
+		"&created_after="+url.QueryEscape(now.Add(-24*time.Hour).Format(time.RFC3339)), []model.Notification{incident}))
+	t.Run("created_before", listNotificationsQuery(conf, "user1", "created_before="+url.QueryEscape(now.Add(-24*time.Hour).Format(time.RFC3339)), []model.Notification{old}))
+
+	t.Run("count", func(t *testing.T) {
+		actual, err := countNotifications(conf, "user1")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		expected := model.NotificationCounts{
+			NotificationCount: model.NotificationCount{Total: 3, Unread: 2},
+			Topics: map[model.Topic]model.NotificationCount{
+				model.TopicIncident:  {Total: 2, Unread: 2},
+				model.TopicDeveloper: {Total: 1, Unread: 0},
+			},
+		}
+		if !reflect.DeepEqual(actual, expected) {
+			t.Error(actual, expected)
+		}
+	})
+
+	t.Run("mark read by ids", func(t *testing.T) {
+		actual, err := setNotificationsReadState(conf, "user1", model.NotificationReadStateRequest{
+			IsRead: true,
+			Ids:    []string{old.Id, developer.Id},
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if !reflect.DeepEqual(actual, model.NotificationReadState{IsRead: true, Ids: []string{old.Id}}) {
+			t.Error(actual)
+		}
+	})
+	old.IsRead = true
+	t.Run("unread after mark by ids", listNotificationsQuery(conf, "user1", "isRead=false", []model.Notification{incident}))
+
+	t.Run("mark unread by filter", func(t *testing.T) {
+		actual, err := setNotificationsReadState(conf, "user1", model.NotificationReadStateRequest{
+			IsRead: false,
+			Filter: &model.NotificationFilter{Topics: []model.Topic{model.TopicIncident}},
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if !reflect.DeepEqual(actual, model.NotificationReadState{IsRead: false, Ids: []string{old.Id}}) {
+			t.Error(actual)
+		}
+	})
+	old.IsRead = false
+	t.Run("unread after mark by filter", listNotificationsQuery(conf, "user1", "isRead=false", []model.Notification{old, incident}))
+
+	t.Run("mark all read", func(t *testing.T) {
+		actual, err := setNotificationsReadState(conf, "user1", model.NotificationReadStateRequest{
+			IsRead: true,
+			Filter: &model.NotificationFilter{},
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(actual.Ids) != 2 {
+			t.Error(actual)
+		}
+	})
+	old.IsRead, incident.IsRead = true, true
+	t.Run("unread after mark all", listNotificationsQuery(conf, "user1", "isRead=false", []model.Notification{}))
+
+	t.Run("cursor paging", func(t *testing.T) {
+		first, err := listNotificationsPage(conf, "user1", "limit=2&sort=created_at.desc&cursor=")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if first.Total != -1 || first.NextCursor == "" || len(first.Notifications) != 2 ||
+			!first.Notifications[0].Equal(developer) || !first.Notifications[1].Equal(incident) {
+			t.Error(first)
+			return
+		}
+		// a notification created between page loads must not shift the next page
+		_, err = createNotification(conf, "user1", model.Notification{Title: "d", Topic: model.TopicIncident}, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		second, err := listNotificationsPage(conf, "user1", "limit=2&sort=created_at.desc&with_total=true&cursor="+url.QueryEscape(first.NextCursor))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if second.Total != 4 || second.NextCursor != "" || len(second.Notifications) != 1 || !second.Notifications[0].Equal(old) {
+			t.Error(second)
+			return
+		}
+	})
+
+	connectorError, err := createNotification(conf, "user2", model.Notification{
+		Title:   "Connector error",
+		Message: "connector lost connection to device",
+		Topic:   model.TopicConnector,
+	}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	connectorInfo, err := createNotification(conf, "user2", model.Notification{
+		Title:   "Info",
+		Message: "connector restarted",
+		Topic:   model.TopicConnector,
+	}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = createNotification(conf, "user2", model.Notification{
+		Title:   "Process finished",
+		Message: "process instance finished",
+		Topic:   model.TopicProcesses,
+	}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	t.Run("search", listNotificationsQuery(conf, "user2", "q=connector+error", []model.Notification{connectorError, connectorInfo}))
+	t.Run("search with topic", listNotificationsQuery(conf, "user2", "q=connector&topic="+model.TopicProcesses, []model.Notification{}))
+
+	deviceOffline, err := createNotification(conf, "user2", model.Notification{
+		Title:    "Device offline",
+		Topic:    model.TopicDeviceOffline,
+		Metadata: map[string]string{"device_id": "device-1", "hub_id": "hub-1"},
+	}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = createNotification(conf, "user2", model.Notification{
+		Title:    "Device offline",
+		Topic:    model.TopicDeviceOffline,
+		Metadata: map[string]string{"device_id": "device-2", "hub_id": "hub-1"},
+	}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	t.Run("label filter", listNotificationsQuery(conf, "user2", "label.device_id=device-1&label.hub_id=hub-1", []model.Notification{deviceOffline}))
+}
+
+func TestNotificationExpiry(t *testing.T) {
+	wg, ctx, cancel, conf, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wg.Wait()
+	defer cancel()
+
+	conf.NotificationCleanupInterval = "500ms"
+
+	err = pkg.Start(ctx, wg, conf)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	time.Sleep(time.Second)
+
+	_, err = setSettings(conf, "user1", model.Settings{
+		RetentionDays: map[model.Topic]int{model.TopicDeviceOffline: 7},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	expiresAt := time.Now().Add(time.Second).Truncate(time.Millisecond)
+	_, err = createNotification(conf, "user1", model.Notification{
+		Title:     "expires",
+		Topic:     model.TopicDeveloper,
+		ExpiresAt: &expiresAt,
+	}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = createNotification(conf, "user1", model.Notification{
+		Title:     "retention exceeded",
+		Topic:     model.TopicDeviceOffline,
+		CreatedAt: time.Now().AddDate(0, 0, -8),
+	}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	recentOffline, err := createNotification(conf, "user1", model.Notification{
+		Title:     "retention not exceeded",
+		Topic:     model.TopicDeviceOffline,
+		CreatedAt: time.Now().AddDate(0, 0, -6).Truncate(time.Millisecond),
+	}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	oldDeveloper, err := createNotification(conf, "user1", model.Notification{
+		Title:     "no retention",
+		Topic:     model.TopicDeveloper,
+		CreatedAt: time.Now().AddDate(0, 0, -8).Truncate(time.Millisecond),
+	}, nil)
+	if err != nil {
+		t.Error(err)
+	}
+
+	time.Sleep(3 * time.Second)
+
+	t.Run("after cleanup", listNotificationsQuery(conf, "user1", "", []model.Notification{oldDeveloper, recentOffline}))
+}
+
+func TestNotificationExpiredHiddenBeforeCleanup(t *testing.T) {
+	wg, ctx, cancel, conf, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wg.Wait()
+	defer cancel()
+
+	conf.NotificationCleanupInterval = "-"
+
+	err = pkg.Start(ctx, wg, conf)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	time.Sleep(time.Second)
+
+	expiresAt := time.Now().Add(time.Second).Truncate(time.Millisecond)
+	expiring, err := createNotification(conf, "user1", model.Notification{
+		Title:     "expires",
+		Topic:     model.TopicDeveloper,
+		ExpiresAt: &expiresAt,
+	}, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	remaining, err := createNotification(conf, "user1", model.Notification{
+		Title: "remains",
+		Topic: model.TopicDeveloper,
+	}, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	t.Run("before expiry", listNotificationsQuery(conf, "user1", "", []model.Notification{expiring, remaining}))
+
+	time.Sleep(2 * time.Second)
+
+	t.Run("after expiry", listNotificationsQuery(conf, "user1", "", []model.Notification{remaining}))
+	t.Run("count after expiry", func(t *testing.T) {
+		counts, err := countNotifications(conf, "user1")
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if counts.Total != 1 || counts.Unread != 1 {
+			t.Error("expected expired notification to be excluded from counts", counts)
+		}
+	})
+	t.Run("read after expiry", func(t *testing.T) {
+		err = readNotification(conf, "user1", expiring.Id, expiring)
+		if err == nil {
+			t.Error("expected expired notification to be not found")
+		}
+	})
+}
+
+func TestNotificationScheduledDelivery(t *testing.T) {
+	wg, ctx, cancel, conf, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wg.Wait()
+	defer cancel()
+
+	conf.ScheduledDeliveryInterval = "500ms"
+
+	err = pkg.Start(ctx, wg, conf)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	time.Sleep(time.Second)
+
+	deliverAt := time.Now().Add(2 * time.Second)
+	scheduled, err := createNotification(conf, "user1", model.Notification{
+		Title:     "maintenance due",
+		Topic:     model.TopicProcesses,
+		DeliverAt: &deliverAt,
+	}, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if scheduled.DeliverAt == nil || !scheduled.CreatedAt.Equal(*scheduled.DeliverAt) {
+		t.Error("unexpected scheduled notification", scheduled)
+	}
+	immediate, err := createNotification(conf, "user1", model.Notification{
+		Title: "immediate",
+		Topic: model.TopicProcesses,
+	}, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	window := int64(60)
+	notDuplicate, err := createNotification(conf, "user1", model.Notification{
+		Title: "maintenance due",
+		Topic: model.TopicProcesses,
+	}, &window)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if notDuplicate.Id == scheduled.Id || notDuplicate.Occurrences != 0 {
+		t.Error("create was counted as duplicate of the scheduled notification", notDuplicate)
+	}
+
+	t.Run("update before delivery", func(t *testing.T) {
+		scheduled.IsRead = true
+		err = updateNotification(conf, "user1", scheduled)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		deliveries, err := listDeliveries(conf, "user1", scheduled.Id)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(deliveries.Jobs) != 0 || len(deliveries.Attempts) != 0 {
+			t.Error("scheduled notification was delivered on update", deliveries)
+		}
+	})
+
+	t.Run("before delivery", listNotificationsQuery(conf, "user1", "", []model.Notification{immediate, notDuplicate}))
+
+	time.Sleep(3 * time.Second)
+
+	scheduled.DeliverAt = nil
+	t.Run("after delivery", listNotificationsQuery(conf, "user1", "", []model.Notification{immediate, notDuplicate, scheduled}))
+}
+
+func TestQuietHoursDeferral(t *testing.T) {
+	wg, ctx, cancel, conf, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer wg.Wait()
+	defer cancel()
+
+	mux := sync.Mutex{}
+	subjects := []string{}
+	mailpit := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		email := struct {
+			Subject string
+		}{}
+		_ = json.NewDecoder(request.Body).Decode(&email)
+		mux.Lock()
+		subjects = append(subjects, email.Subject)
+		mux.Unlock()
+		_, _ = writer.Write([]byte(`{"ID":"test"}`))
+	}))
+	defer mailpit.Close()
+	conf.MailpitHostPort = mailpit.URL
+	conf.ScheduledDeliveryInterval = "500ms"
+
+	err = pkg.Start(ctx, wg, conf)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	time.Sleep(time.Second)
+
+	// quiet hours have a resolution of minutes and end with the next minute
+	now := time.Now().UTC()
+	if now.Second() >= 50 {
+		time.Sleep(time.Duration(61-now.Second()) * time.Second)
+		now = time.Now().UTC()
+	}
+	end := now.Truncate(time.Minute).Add(time.Minute)
+	settings := model.DefaultSettings()
+	settings.QuietHours = map[model.Channel]model.QuietHours{
+		model.ChannelEmail: {
+			Start:    now.Add(-time.Hour).Format("15:04"),
+			End:      end.Format("15:04"),
+			TimeZone: "UTC",
+			Defer:    true,
+		},
+	}
+	_, err = setSettings(conf, "mailuser1", settings)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = createNotification(conf, "mailuser1", model.Notification{Title: "quiet"}, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	time.Sleep(2 * time.Second)
+	mux.Lock()
+	if len(subjects) != 0 {
+		t.Error("expected email to be deferred during quiet hours", subjects)
+	}
+	mux.Unlock()
+
+	time.Sleep(time.Until(end) + 3*time.Second)
+	mux.Lock()
+	if !slices.Equal(subjects, []string{"quiet"}) {
+		t.Error("expected deferred email after quiet hours", subjects)
+	}
+	mux.Unlock()
+}
+
+func setSettings(config configuration.Config, userId string, settings model.Settings) (result model.Settings, err error) {
+	token, err := createToken(userId)
+	if err != nil {
+		return result, err
+	}
+	b := new(bytes.Buffer)
+	err = json.NewEncoder(b).Encode(settings)
+	if err != nil {
+		return result, err
+	}
+	req, err := http.NewRequest("PUT", "http://localhost:"+config.ApiPort+"/settings", b)
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Authorization", token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return result, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return result, errors.New(string(b))
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	return
+}
+
+func setNotificationsReadState(config configuration.Config, userId string, request model.NotificationReadStateRequest) (result model.NotificationReadState, err error) {
+	token, err := createToken(userId)
+	if err != nil {
+		return result, err
+	}
+	b := new(bytes.Buffer)
+	err = json.NewEncoder(b).Encode(request)
+	if err != nil {
+		return result, err
+	}
+	req, err := http.NewRequest("PUT", "http://localhost:"+config.ApiPort+"/notifications/read-state", b)
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Authorization", token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return result, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return result, errors.New(string(b))
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	return
+}
+
+func resolveNotification(config configuration.Config, userId string, request model.NotificationResolveRequest) (result model.Notification, err error) {
+	token, err := createToken(userId)
+	if err != nil {
+		return result, err
+	}
+	b := new(bytes.Buffer)
+	err = json.NewEncoder(b).Encode(request)
+	if err != nil {
+		return result, err
+	}
+	req, err := http.NewRequest("POST", "http://localhost:"+config.ApiPort+"/notifications/resolve", b)
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Authorization", token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return result, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return result, errors.New(string(b))
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	return
+}
+
+func listDeliveries(config configuration.Config, userId string, id string) (result model.NotificationDeliveries, err error) {
+	token, err := createToken(userId)
+	if err != nil {
+		return result, err
+	}
+	req, err := http.NewRequest("GET", "http://localhost:"+config.ApiPort+"/notifications/"+id+"/deliveries", nil)
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Authorization", token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return result, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return result, errors.New(string(b))
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	return
+}
+
+func listNotificationsPage(config configuration.Config, userId string, query string) (result model.NotificationList, err error) {
+	token, err := createToken(userId)
+	if err != nil {
+		return result, err
+	}
+	req, err := http.NewRequest("GET", "http://localhost:"+config.ApiPort+"/notifications?"+query, nil)
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Authorization", token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return result, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return result, errors.New(string(b))
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	return
+}
+
+func countNotifications(config configuration.Config, userId string) (result model.NotificationCounts, err error) {
+	token, err := createToken(userId)
+	if err != nil {
+		return result, err
+	}
+	req, err := http.NewRequest("GET", "http://localhost:"+config.ApiPort+"/notifications/count", nil)
+	if err != nil {
+		return result, err
+	}
+	req.Header.Set("Authorization", token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return result, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(resp.Body)
+		return result, errors.New(string(b))
+	}
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	return
+}
+
+func listNotificationsQuery(config configuration.Config, userId string, query string, expected []model.Notification) func(t *testing.T) {
+	return func(t *testing.T) {
+		actual, err := listNotificationsPage(config, userId, "limit=10&"+query)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if int(actual.Total) != len(expected) || len(actual.Notifications) != len(expected) {
+			t.Error(actual, expected)
+			return
+		}
+		for i := range expected {
+			if !actual.Notifications[i].Equal(expected[i]) {
+				t.Error(actual, expected)
+				return
+			}
+		}
+	}
 }
 
 func listNotifications(config configuration.Config, userId string, expected model.NotificationList) func(t *testing.T) {
@@ -263,6 +1081,14 @@ func listNotifications(config configuration.Config, userId string, expected mode
 }
 
 func createNotification(config configuration.Config, userId string, notification model.Notification, ignoreDuplicatesWithinSeconds *int64) (result model.Notification, err error) {
+	query := ""
+	if ignoreDuplicatesWithinSeconds != nil {
+		query = "ignore_duplicates_within_seconds=" + strconv.FormatInt(*ignoreDuplicatesWithinSeconds, 10)
+	}
+	return createNotificationWithQuery(config, userId, notification, query)
+}
+
+func createNotificationWithQuery(config configuration.Config, userId string, notification model.Notification, query string) (result model.Notification, err error) {
 	var token string
 	token, err = createToken(userId)
 	if err != nil {
@@ -274,8 +1100,8 @@ func createNotification(config configuration.Config, userId string, notification
 		return
 	}
 	url := "http://localhost:" + config.ApiPort + "/notifications"
-	if ignoreDuplicatesWithinSeconds != nil {
-		url += "?ignore_duplicates_within_seconds=" + strconv.FormatInt(*ignoreDuplicatesWithinSeconds, 10)
+	if query != "" {
+		url += "?" + query
 	}
 	req, err := http.NewRequest("POST", url, b)
 	if err != nil {
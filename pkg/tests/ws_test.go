@@ -139,7 +139,7 @@ func TestWebSocket(t *testing.T) {
 
 	mux.Lock()
 	defer mux.Unlock()
-	if len(messages) != 4 {
+	if len(messages) != 5 {
 		t.Error(messages)
 		return
 	}
@@ -158,12 +158,22 @@ func TestWebSocket(t *testing.T) {
 		return
 	}
 
-	if messages[2].Type != model.WsListType {
+	if messages[2].Type != model.WsCountType {
+		t.Error(messages[2])
+		t.Error(messages)
+		return
+	}
+	counts, ok := messages[2].Payload.(map[string]interface{})
+	if !ok || counts["total"] != float64(1) || counts["unread"] != float64(1) {
+		t.Error("unexpected count content", messages[2].Payload)
+	}
+
+	if messages[3].Type != model.WsListType {
 		t.Error(messages[3])
 		t.Error(messages)
 		return
 	}
-	list, ok := messages[2].Payload.([]interface{})
+	list, ok := messages[3].Payload.([]interface{})
 	if !ok {
 		t.Error("unexpected list type")
 		return
@@ -187,8 +197,8 @@ func TestWebSocket(t *testing.T) {
 		return
 	}
 
-	if messages[3].Type != model.WsAuthRequestType {
-		t.Error(messages[3])
+	if messages[4].Type != model.WsAuthRequestType {
+		t.Error(messages[4])
 		t.Error(messages)
 		return
 	}